@@ -0,0 +1,199 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/friedelschoen/zon/types"
+	"github.com/klauspost/compress/zstd"
+	flag "github.com/spf13/pflag"
+)
+
+/* addTree writes the files in `root` to `tw` below `prefix` */
+func addTree(tw *tar.Writer, root string, prefix string) error {
+	return filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(name); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			file, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(tw, file)
+			return err
+		}
+		return nil
+	})
+}
+
+func writeJSON(tw *tar.Writer, name string, value any) error {
+	content, err := json.MarshalIndent(value, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	_, err = tw.Write(content)
+	return err
+}
+
+/* exportClosure writes the store-entries `names` of the closure of `root` as zstd-compressed tar to `w` */
+func exportClosure(w io.Writer, cachedir string, index map[string]types.IndexEntry, root string, names []string) error {
+	manifest := types.ArchiveManifest{Root: root, StoreRoot: cachedir, Entries: make(map[string]string)}
+	for _, name := range names {
+		/* entries are hashed with the algorithm of their key, if it can hash content */
+		algorithm := index[name].Algorithm
+		if _, err := types.NewHash(algorithm); err != nil {
+			algorithm = types.DefaultHash
+		}
+		hash, err := types.ContentHash(storePath(cachedir, index, name), algorithm)
+		if err != nil {
+			return err
+		}
+		manifest.Entries[name] = hash
+	}
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+	if err := writeJSON(tw, "manifest.json", manifest); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if entry, ok := index[name]; ok {
+			if err := writeJSON(tw, path.Join("index", name+".json"), entry); err != nil {
+				return err
+			}
+		}
+		if err := addTree(tw, storePath(cachedir, index, name), path.Join("store", name)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func exportMain(args []string) {
+	var ev types.Evaluator
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon export [options] <result|name|hash> > bundle.tar.zst\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	index, err := types.ReadIndex(ev.CacheDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	root, err := storeEntry(ev.CacheDir, index, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := exportClosure(os.Stdout, ev.CacheDir, index, root, closure(index, root)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func importMain(args []string) {
+	var (
+		ev         types.Evaluator
+		resultName string
+		missing    bool
+		relocate   bool
+	)
+
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon import [options] [bundle.tar.zst]\n")
+		fmt.Fprintf(os.Stderr, "       zon import [options] --missing <name...>\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.StringVarP(&resultName, "output", "o", "", "create a result-symlink `name` to the imported result")
+	flags.BoolVar(&relocate, "relocate", false, "import archives of another store, references to that store are not rewritten")
+	flags.BoolVar(&missing, "missing", false, "print the given store-names which are not in the store and exit")
+	flags.Parse(args)
+
+	if missing {
+		for _, name := range flags.Args() {
+			if !storeExists(ev.CacheDir, name) {
+				fmt.Println(name)
+			}
+		}
+		return
+	}
+
+	var in io.Reader = os.Stdin
+	if flags.NArg() > 0 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	os.MkdirAll(ev.CacheDir, 0755)
+	root, imported, err := types.ImportArchive(in, ev.CacheDir, "", relocate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, name := range imported {
+		fmt.Fprintf(os.Stderr, "import %s\n", name)
+	}
+	if resultName != "" {
+		res := types.PathExpr{Name: path.Join(ev.CacheDir, root)}
+		if err := res.Link(resultName); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
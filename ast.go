@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+var positionType = reflect.TypeFor[types.Position]()
+
+/* astKind returns the name of the node-type of `typ`, without the Expr-suffix */
+func astKind(typ reflect.Type) string {
+	return strings.TrimSuffix(typ.Name(), "Expr")
+}
+
+/* astFields returns the exported fields of the node `v`, except its position */
+func astFields(v reflect.Value) (names []string, values []reflect.Value) {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Type == positionType {
+			continue
+		}
+		names = append(names, strings.ToLower(field.Name[:1])+field.Name[1:])
+		values = append(values, v.Field(i))
+	}
+	return names, values
+}
+
+/* astJSON converts `v` to JSON, nodes become objects with their kind and position followed by their fields */
+func astJSON(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return astJSON(v.Elem())
+	case reflect.Struct:
+		node := types.OrderedMap{Keys: []string{"kind"}, Values: map[string]any{"kind": astKind(v.Type())}}
+		if pos, ok := v.Interface().(types.Expression); ok {
+			node.Keys = append(node.Keys, "position")
+			node.Values["position"] = pos.Location().Pos()
+		}
+		names, values := astFields(v)
+		for i, name := range names {
+			node.Keys = append(node.Keys, name)
+			node.Values[name] = astJSON(values[i])
+		}
+		return node
+	case reflect.Slice:
+		elems := make([]any, v.Len())
+		for i := range elems {
+			elems[i] = astJSON(v.Index(i))
+		}
+		return elems
+	case reflect.Map:
+		entries := make(map[string]any)
+		for _, key := range v.MapKeys() {
+			entries[key.String()] = astJSON(v.MapIndex(key))
+		}
+		return entries
+	}
+	return v.Interface()
+}
+
+/* writeSexp writes `v` as s-expression, nodes are written as `(Kind position :field value ...)` */
+func writeSexp(w io.Writer, v reflect.Value, depth int) {
+	indent := "\n" + strings.Repeat("  ", depth+1)
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			fmt.Fprint(w, "nil")
+			return
+		}
+		writeSexp(w, v.Elem(), depth)
+	case reflect.Struct:
+		fmt.Fprintf(w, "(%s", astKind(v.Type()))
+		if pos, ok := v.Interface().(types.Expression); ok {
+			fmt.Fprintf(w, " %s", pos.Location().Pos())
+		}
+		names, values := astFields(v)
+		for i, name := range names {
+			fmt.Fprintf(w, "%s:%s ", indent, name)
+			writeSexp(w, values[i], depth+1)
+		}
+		fmt.Fprint(w, ")")
+	case reflect.Slice:
+		if v.Len() == 0 {
+			fmt.Fprint(w, "()")
+			return
+		}
+		fmt.Fprint(w, "(")
+		for i := range v.Len() {
+			fmt.Fprint(w, indent)
+			writeSexp(w, v.Index(i), depth+1)
+		}
+		fmt.Fprint(w, ")")
+	case reflect.Map:
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		fmt.Fprint(w, "(")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s(%s ", indent, strconv.Quote(key.String()))
+			writeSexp(w, v.MapIndex(key), depth+1)
+			fmt.Fprint(w, ")")
+		}
+		fmt.Fprint(w, ")")
+	case reflect.String:
+		fmt.Fprint(w, strconv.Quote(v.String()))
+	default:
+		fmt.Fprint(w, v.Interface())
+	}
+}
+
+func astMain(args []string) {
+	var sexp bool
+
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon ast [options] <file.zon>\n")
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&sexp, "sexp", false, "print the tree as s-expressions instead of JSON")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	ast, err := parser.ParseFile(types.PathExpr{Position: types.Position{Filename: "<commandline>"}, Name: flags.Arg(0)})
+	if err != nil {
+		exitError(err)
+	}
+
+	if sexp {
+		writeSexp(os.Stdout, reflect.ValueOf(ast), 0)
+		fmt.Println()
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	enc.Encode(astJSON(reflect.ValueOf(ast)))
+}
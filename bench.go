@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* measurements of a phase of the benchmark */
+type benchPhase struct {
+	name      string
+	durations []time.Duration
+	allocs    uint64 /* total over every run */
+	bytes     uint64
+}
+
+/* measure runs `fn` and records its duration and allocations */
+func (phase *benchPhase) measure(fn func() error) error {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	phase.durations = append(phase.durations, elapsed)
+	phase.allocs += after.Mallocs - before.Mallocs
+	phase.bytes += after.TotalAlloc - before.TotalAlloc
+	return err
+}
+
+func (phase *benchPhase) print() {
+	sorted := slices.Clone(phase.durations)
+	slices.Sort(sorted)
+	runs := uint64(len(sorted))
+	fmt.Printf("%-10s %10v %10v %10v %12d %10s\n", phase.name,
+		sorted[0].Round(time.Microsecond), sorted[len(sorted)/2].Round(time.Microsecond), sorted[len(sorted)-1].Round(time.Microsecond),
+		phase.allocs/runs, formatSize(int64(phase.bytes/runs)))
+}
+
+func benchMain(args []string) {
+	var runs int
+
+	/* every run gets a fresh evaluator, configured by the same flags */
+	newEvaluator := func() (*types.Evaluator, []string) {
+		ev := new(types.Evaluator)
+		flags := flag.NewFlagSet("bench", flag.ExitOnError)
+		flags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "usage: zon bench [options] <file.zon> [key=value ...]\n")
+			flags.PrintDefaults()
+		}
+		evaluatorFlags(flags, ev)
+		flags.IntVarP(&runs, "count", "n", 10, "parse and evaluate `n` times")
+		flags.Parse(args)
+		if flags.NArg() == 0 || runs < 1 {
+			flags.Usage()
+			os.Exit(1)
+		}
+		ev.DryRun = true
+		return ev, flags.Args()
+	}
+
+	/* the first run fetches inputs and prints warnings */
+	ev, evalArgs := newEvaluator()
+	if _, _, err := evaluate(evalArgs, ev); err != nil {
+		exitError(err)
+	}
+	quietWarnings = true
+
+	filename := ""
+	for _, arg := range evalArgs {
+		if !strings.Contains(arg, "=") {
+			filename = arg
+			break
+		}
+	}
+
+	parse := benchPhase{name: "parse"}
+	eval := benchPhase{name: "evaluate"}
+	for range runs {
+		err := parse.measure(func() error {
+			_, err := parser.ParseFile(types.PathExpr{Position: types.Position{Filename: "<commandline>"}, Name: filename})
+			return err
+		})
+		if err != nil {
+			exitError(err)
+		}
+		ev, evalArgs := newEvaluator()
+		err = eval.measure(func() error {
+			_, _, err := evaluate(evalArgs, ev)
+			return err
+		})
+		if err != nil {
+			exitError(err)
+		}
+	}
+
+	fmt.Printf("%-10s %10s %10s %10s %12s %10s\n", "", "min", "median", "max", "allocs/run", "bytes/run")
+	parse.print()
+	eval.print()
+}
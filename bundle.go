@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* launcher of a bundle, it extracts the archive appended to it once and executes the program */
+var launcherTemplate = template.Must(template.New("launcher").Parse(`#!/bin/sh
+# self-extracting bundle of {{.Root}}, created by zon
+set -e
+dir="${ZON_BUNDLE_DIR:-${XDG_CACHE_HOME:-$HOME/.cache}/zon-bundle}/{{.Root}}"
+if [ ! -d "$dir" ]; then
+	tmp="$dir.tmp.$$"
+	mkdir -p "$tmp"
+	tail -n +{{.Skip}} "$0" | gzip -dc | tar -xf - -C "$tmp"
+	mv "$tmp" "$dir" 2>/dev/null || rm -rf "$tmp"
+fi
+PATH="$dir/{{.Root}}/bin:$PATH" exec "$dir/{{.Root}}/{{.Program}}" "$@"
+`))
+
+/* writeBundle writes the launcher of `program` in `root` followed by the gzipped closure of `root` to `w` */
+func writeBundle(w io.Writer, cachedir string, index map[string]types.IndexEntry, root string, program string) error {
+	var launcher bytes.Buffer
+	data := struct {
+		Root, Program string
+		Skip          int
+	}{Root: root, Program: program}
+	/* the launcher has a fixed number of lines, the archive starts right after it */
+	if err := launcherTemplate.Execute(&launcher, data); err != nil {
+		return err
+	}
+	data.Skip = strings.Count(launcher.String(), "\n") + 1
+	launcher.Reset()
+	if err := launcherTemplate.Execute(&launcher, data); err != nil {
+		return err
+	}
+	if _, err := w.Write(launcher.Bytes()); err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for _, name := range closure(index, root) {
+		if err := addTree(tw, storePath(cachedir, index, name), name); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func bundleMain(args []string) {
+	var (
+		ev      types.Evaluator
+		outfile string
+		program string
+	)
+
+	flags := flag.NewFlagSet("bundle", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon bundle [options] <result|name|hash> -o <file>\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.StringVarP(&outfile, "output", "o", "", "write the bundle to `file` instead of stdout")
+	flags.StringVar(&program, "program", "", "executable relative to the result, `mainProgram` of the result by default")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	index, err := types.ReadIndex(ev.CacheDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	root, err := storeEntry(ev.CacheDir, index, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if program == "" {
+		program = index[root].MainProgram
+	}
+	if program == "" {
+		fmt.Fprintf(os.Stderr, "%s has no mainProgram, use --program\n", root)
+		os.Exit(1)
+	}
+	program = path.Clean(program)
+
+	var out io.Writer = os.Stdout
+	if outfile != "" {
+		file, err := os.OpenFile(outfile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := writeBundle(out, ev.CacheDir, index, root, program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
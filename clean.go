@@ -0,0 +1,96 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+type cleanOptions struct {
+	dryRun    bool
+	yes       bool /* do not ask for confirmation */
+	olderThan time.Duration
+	keepLast  int
+}
+
+/* clean removes store entries not used by the last evaluation of `ev` nor reachable from a result-symlink */
+func clean(ev *types.Evaluator, opts cleanOptions) error {
+	entries, err := os.ReadDir(ev.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	type candidate struct {
+		name    string
+		modtime time.Time
+	}
+
+	index, err := types.ReadIndex(ev.CacheDir)
+	if err != nil {
+		return err
+	}
+	keep, err := liveEntries(ev, index)
+	if err != nil {
+		return err
+	}
+
+	/* entries per output-name, to honor keepLast */
+	byName := make(map[string][]candidate)
+	for _, entry := range entries {
+		/* impure outputs are pruned when they are built, hidden entries are temporary */
+		if entry.Name() == types.ImpureDir || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := index[entry.Name()].Name
+		if name == "" {
+			_, name, _ = strings.Cut(entry.Name(), "-")
+		}
+		byName[name] = append(byName[name], candidate{entry.Name(), info.ModTime()})
+	}
+
+	var remove []string
+	for _, cands := range byName {
+		slices.SortFunc(cands, func(a, b candidate) int {
+			return b.modtime.Compare(a.modtime)
+		})
+		for i, cand := range cands {
+			if i < opts.keepLast {
+				continue
+			}
+			if opts.olderThan > 0 && time.Since(cand.modtime) < opts.olderThan {
+				continue
+			}
+			if keep[cand.name] {
+				continue
+			}
+			remove = append(remove, cand.name)
+		}
+	}
+	slices.SortFunc(remove, cmp.Compare)
+
+	if opts.dryRun {
+		for _, name := range remove {
+			fmt.Printf("would clean %s\n", name)
+		}
+		return nil
+	}
+	if !confirm("cleaned", remove, opts.yes) {
+		return nil
+	}
+	for _, name := range remove {
+		fmt.Printf("clean %s\n", name)
+		os.RemoveAll(path.Join(ev.CacheDir, name))
+		types.RemoveIndex(ev.CacheDir, name)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+func TestClean(t *testing.T) {
+	cachedir := t.TempDir()
+	for _, name := range []string{"root", "dep", "output", "stale"} {
+		os.MkdirAll(path.Join(cachedir, name), 0755)
+	}
+	/* `root` is the target of a result-symlink and depends on `dep` */
+	link := path.Join(t.TempDir(), "result")
+	os.Symlink(path.Join(cachedir, "root"), link)
+	os.MkdirAll(path.Join(cachedir, types.RootsDir), 0755)
+	os.Symlink(link, path.Join(cachedir, types.RootsDir, "result"))
+	content, _ := json.Marshal(types.IndexEntry{Name: "root", Path: path.Join(cachedir, "root"), Depends: []string{"dep"}})
+	os.MkdirAll(path.Join(cachedir, types.IndexDir), 0755)
+	os.WriteFile(path.Join(cachedir, types.IndexDir, "root.json"), content, 0644)
+
+	ev := &types.Evaluator{
+		CacheDir: cachedir,
+		Outputs:  []types.OutputInfo{{Path: path.Join(cachedir, "output")}},
+	}
+	if err := clean(ev, cleanOptions{yes: true}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"root": true, "dep": true, "output": true, "stale": false} {
+		if _, err := os.Stat(path.Join(cachedir, name)); (err == nil) != want {
+			t.Errorf("%s exists: %v, want %v", name, err == nil, want)
+		}
+	}
+}
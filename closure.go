@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* storeEntry returns the name of the store-entry addressed by `query`,
+ * which is a result-symlink, a store path, which may point inside of a store-entry, or a name or hash in the index */
+func storeEntry(cachedir string, index map[string]types.IndexEntry, query string) (string, error) {
+	if target, err := filepath.EvalSymlinks(query); err == nil {
+		if store, err := filepath.EvalSymlinks(cachedir); err == nil {
+			if rel, ok := strings.CutPrefix(target, store+"/"); ok {
+				name, _, _ := strings.Cut(strings.TrimPrefix(rel, types.ImpureDir+"/"), "/")
+				target = path.Join(store, name)
+			}
+		}
+		if _, ok := index[path.Base(target)]; ok || storeExists(cachedir, path.Base(target)) {
+			return path.Base(target), nil
+		}
+	}
+	matches, err := types.LookupIndex(cachedir, query)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no store-entry named %s", query)
+	}
+	return matches[0].StoreName(), nil
+}
+
+/* closure returns the store-names of `root` and all its transitive dependencies, sorted */
+func closure(index map[string]types.IndexEntry, root string) []string {
+	seen := make(map[string]bool)
+	var walk func(name string)
+	walk = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		for _, dep := range index[name].Depends {
+			walk(dep)
+		}
+	}
+	walk(root)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+/* storePath returns the path of store-entry `name`, which may be an impure output */
+func storePath(cachedir string, index map[string]types.IndexEntry, name string) string {
+	if entry, ok := index[name]; ok {
+		return entry.Path
+	}
+	return path.Join(cachedir, name)
+}
+
+func closureMain(args []string) {
+	var (
+		ev         types.Evaluator
+		jsonOutput bool
+	)
+
+	flags := flag.NewFlagSet("closure", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon closure [options] <result|name|hash>\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.BoolVar(&jsonOutput, "json", false, "print the closure as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	index, err := types.ReadIndex(ev.CacheDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	root, err := storeEntry(ev.CacheDir, index, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var (
+		total   int64
+		entries []any
+	)
+	for _, name := range closure(index, root) {
+		p := storePath(ev.CacheDir, index, name)
+		size := diskUsage(p)
+		total += size
+		if jsonOutput {
+			entries = append(entries, map[string]any{"path": p, "size": size})
+		} else {
+			fmt.Printf("%10s  %s\n", formatSize(size), p)
+		}
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		enc.Encode(map[string]any{"paths": entries, "size": total})
+	} else {
+		fmt.Printf("%10s  total\n", formatSize(total))
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+func TestStoreEntry(t *testing.T) {
+	cachedir := t.TempDir()
+	os.MkdirAll(path.Join(cachedir, "hash-pkg", "bin"), 0755)
+	os.MkdirAll(path.Join(cachedir, types.ImpureDir, "hash-impure", "bin"), 0755)
+	index := map[string]types.IndexEntry{
+		"hash-pkg": {Name: "pkg", Hash: "hash", Path: path.Join(cachedir, "hash-pkg")},
+	}
+	result := path.Join(t.TempDir(), "result")
+	os.Symlink(path.Join(cachedir, "hash-pkg"), result)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{result, "hash-pkg"},
+		{path.Join(result, "bin"), "hash-pkg"},
+		{path.Join(cachedir, "hash-pkg"), "hash-pkg"},
+		{path.Join(cachedir, "hash-pkg", "bin"), "hash-pkg"},
+		{path.Join(cachedir, types.ImpureDir, "hash-impure", "bin"), "hash-impure"},
+	}
+	for _, test := range tests {
+		if got, err := storeEntry(cachedir, index, test.query); err != nil || got != test.want {
+			t.Errorf("storeEntry(%s) = %s, %v, want %s", test.query, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/friedelschoen/zon/types"
+)
+
+/* defaults for the command line, read from the user and project configuration */
+type Config struct {
+	CacheDir       string                      `toml:"cache"`
+	LogDir         string                      `toml:"log"`
+	Jobs           int                         `toml:"jobs"`
+	Interpreter    string                      `toml:"interpreter"`
+	Substituters   []string                    `toml:"substituters"`
+	Registry       map[string]string           `toml:"registry"`
+	Strip          []string                    `toml:"strip"`
+	Sandbox        bool                        `toml:"sandbox"`
+	Verify         bool                        `toml:"verify"`
+	KeepGoing      bool                        `toml:"keep-going"`
+	LegacyEnv      bool                        `toml:"legacy-env"`
+	Secrets        string                      `toml:"secrets"`
+	SecretsCommand string                      `toml:"secrets-command"`
+	Redact         []string                    `toml:"redact"`
+	Proxy          string                      `toml:"proxy"`
+	HashAlgorithm  string                      `toml:"hash-algorithm"`
+	StoreKeyLength int                         `toml:"store-key-length"`
+	BuildUser      string                      `toml:"build-user"`
+	MaxStoreSize   string                      `toml:"max-store-size"`
+	CompressLogs   string                      `toml:"compress-logs"`
+	NotifyCmd      string                      `toml:"notify-cmd"`
+	NotifyWebhook  string                      `toml:"notify-webhook"`
+	SortKeys       bool                        `toml:"sort-keys"`
+	Netrc          string                      `toml:"netrc"`
+	Hosts          map[string]types.HostConfig `toml:"hosts"`
+}
+
+var config = Config{
+	CacheDir:     defaultCacheDir("store"),
+	LogDir:       defaultCacheDir("log"),
+	Interpreter:  "sh",
+	CompressLogs: "1M",
+}
+
+/* defaultCacheDir returns `name` inside $XDG_CACHE_HOME/zon, or inside ./cache if there is no cache-directory */
+func defaultCacheDir(name string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return path.Join("cache", name)
+	}
+	return path.Join(dir, "zon", name)
+}
+
+/* configFiles returns the configuration files in order of precedence, lowest first */
+func configFiles() []string {
+	var files []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, path.Join(dir, "zon", "config.toml"))
+	}
+	return append(files, "zon.toml")
+}
+
+/* loadConfig reads every existing configuration file, later files override earlier ones */
+func loadConfig(cfg *Config) error {
+	for _, filename := range configFiles() {
+		meta, err := toml.DecodeFile(filename, cfg)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		/* a relative store is relative to the file it is configured in */
+		if meta.IsDefined("cache") && !filepath.IsAbs(cfg.CacheDir) {
+			cfg.CacheDir = filepath.Join(filepath.Dir(filename), cfg.CacheDir)
+		}
+	}
+	/* a relative store would be resolved differently in every directory */
+	var err error
+	cfg.CacheDir, err = filepath.Abs(cfg.CacheDir)
+	return err
+}
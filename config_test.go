@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigCache(t *testing.T) {
+	confdir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", confdir)
+	os.MkdirAll(filepath.Join(confdir, "zon"), 0755)
+	if err := os.WriteFile(filepath.Join(confdir, "zon", "config.toml"), []byte("cache = \"store\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	/* the store does not depend on the directory zon is run in */
+	for _, dir := range []string{t.TempDir(), t.TempDir()} {
+		wd, _ := os.Getwd()
+		if err := os.Chdir(dir); err != nil {
+			t.Fatal(err)
+		}
+		var cfg Config
+		err := loadConfig(&cfg)
+		os.Chdir(wd)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := filepath.Join(confdir, "zon", "store"); cfg.CacheDir != want {
+			t.Errorf("got cache %s, want %s", cfg.CacheDir, want)
+		}
+	}
+
+	/* zon.toml is relative to the project */
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "zon.toml"), []byte("cache = \"local\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(project); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	var cfg Config
+	if err := loadConfig(&cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := filepath.Join(project, "local"); cfg.CacheDir != want {
+		t.Errorf("got cache %s, want %s", cfg.CacheDir, want)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/* confirm lists `items` which are about to be `action`, e.g. "cleaned", and asks whether to continue, unless `yes`,
+ * nothing is removed without `yes` if stdin is not a terminal */
+func confirm(action string, items []string, yes bool) bool {
+	if len(items) == 0 || yes {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "%d entries will be %s:\n", len(items), action)
+	for _, item := range items {
+		fmt.Fprintf(os.Stderr, "  %s\n", item)
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		fmt.Fprintf(os.Stderr, "not removing %d entries without a terminal, confirm with --yes\n", len(items))
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "remove %d entries? [y/N] ", len(items))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
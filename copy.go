@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* remote zon-store reached over ssh */
+type remoteStore struct {
+	Host     string /* [user@]host */
+	Port     string
+	CacheDir string /* store on the remote, default of the remote if empty */
+	Program  string /* zon-executable on the remote */
+	Relocate bool   /* import into a store at another path */
+}
+
+/* parseRemote parses `ssh://[user@]host[:port][/cachedir]` */
+func parseRemote(target string) (remoteStore, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return remoteStore{}, err
+	}
+	if u.Scheme != "ssh" || u.Hostname() == "" {
+		return remoteStore{}, fmt.Errorf("invalid remote %s, expected ssh://[user@]host[:port][/cachedir]", target)
+	}
+	remote := remoteStore{Host: u.Hostname(), Port: u.Port(), CacheDir: u.Path}
+	if u.User != nil {
+		remote.Host = u.User.Username() + "@" + remote.Host
+	}
+	return remote, nil
+}
+
+/* command returns ssh running zon `subcommand` with `args` on the remote */
+func (remote remoteStore) command(subcommand string, args ...string) *exec.Cmd {
+	if remote.CacheDir != "" {
+		args = append([]string{"--cache", remote.CacheDir}, args...)
+	}
+	cmdline := []string{shellQuote(remote.Program), shellQuote(subcommand)}
+	for _, arg := range args {
+		cmdline = append(cmdline, shellQuote(arg))
+	}
+	sshargs := []string{}
+	if remote.Port != "" {
+		sshargs = append(sshargs, "-p", remote.Port)
+	}
+	sshargs = append(sshargs, remote.Host, strings.Join(cmdline, " "))
+	cmd := exec.Command("ssh", sshargs...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+/* missing returns the store-names of `names` which the remote does not have */
+func (remote remoteStore) missing(names []string) ([]string, error) {
+	cmd := remote.command("import", append([]string{"--missing"}, names...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to query %s: %w", remote.Host, err)
+	}
+	var result []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result, nil
+}
+
+/* copyClosure copies `root` and its closure to `remote`, skipping store-entries the remote already has */
+func copyClosure(remote remoteStore, cachedir string, index map[string]types.IndexEntry, root string) error {
+	names, err := remote.missing(closure(index, root))
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "%s is up to date\n", remote.Host)
+		return nil
+	}
+
+	var cmd *exec.Cmd
+	if remote.Relocate {
+		cmd = remote.command("import", "--relocate")
+	} else {
+		cmd = remote.command("import")
+	}
+	pr, pw := io.Pipe()
+	cmd.Stdin = pr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	err = exportClosure(pw, cachedir, index, root, names)
+	pw.CloseWithError(err)
+	if werr := cmd.Wait(); werr != nil && err == nil {
+		err = fmt.Errorf("unable to import on %s: %w", remote.Host, werr)
+	}
+	return err
+}
+
+func copyMain(args []string) {
+	var (
+		ev     types.Evaluator
+		to     string
+		remote remoteStore
+	)
+
+	flags := flag.NewFlagSet("copy", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon copy [options] --to ssh://[user@]host[:port][/cachedir] <result|name|hash>\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.StringVar(&to, "to", "", "remote to copy to")
+	flags.StringVar(&remote.Program, "remote-program", "zon", "zon-executable on the remote")
+	flags.BoolVar(&remote.Relocate, "relocate", false, "copy into a store at another path than the local one, references are not rewritten")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || to == "" {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	parsed, err := parseRemote(to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	parsed.Program = remote.Program
+	parsed.Relocate = remote.Relocate
+	remote = parsed
+
+	index, err := types.ReadIndex(ev.CacheDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	root, err := storeEntry(ev.CacheDir, index, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := copyClosure(remote, ev.CacheDir, index, root); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* depsJSON describes a dependency for SBOM-tooling */
+func depsJSON(p types.PathExpr) map[string]any {
+	name, hash := pathLabel(p)
+	kind := "source"
+	if p.OutputName != "" {
+		kind = "output"
+	}
+	return map[string]any{
+		"name":     name,
+		"hash":     hash,
+		"path":     p.Name,
+		"kind":     kind,
+		"position": p.Pos(),
+	}
+}
+
+/* dependencies returns the direct and transitive dependencies of `root`, in breadth-first order without duplicates */
+func dependencies(root types.PathExpr) (direct []types.PathExpr, transitive []types.PathExpr) {
+	seen := map[string]bool{root.Name: true}
+	var queue []types.PathExpr
+	for _, dep := range root.Depends {
+		if !seen[dep.Name] {
+			seen[dep.Name] = true
+			direct = append(direct, dep)
+			queue = append(queue, dep)
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, dep := range p.Depends {
+			if !seen[dep.Name] {
+				seen[dep.Name] = true
+				transitive = append(transitive, dep)
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return direct, transitive
+}
+
+/* findPath returns the first path reachable from `roots` matching `query` */
+func findPath(roots []types.PathExpr, query string) (types.PathExpr, bool) {
+	seen := make(map[string]bool)
+	queue := roots
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if seen[p.Name] {
+			continue
+		}
+		seen[p.Name] = true
+		if matchPath(p, query) {
+			return p, true
+		}
+		queue = append(queue, p.Depends...)
+	}
+	return types.PathExpr{}, false
+}
+
+func depsMain(args []string) {
+	var (
+		ev         types.Evaluator
+		jsonOutput bool
+		build      bool
+	)
+
+	flags := flag.NewFlagSet("deps", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon deps [options] <file.zon> [target] [key=value ...]\n")
+		flags.PrintDefaults()
+	}
+	evaluatorFlags(flags, &ev)
+	flags.BoolVar(&jsonOutput, "json", false, "print the dependencies as JSON")
+	flags.BoolVar(&build, "build", false, "build the outputs before listing their dependencies")
+	flags.Parse(args)
+
+	var evalArgs, queries []string
+	for i, arg := range flags.Args() {
+		if i == 0 || strings.Contains(arg, "=") {
+			evalArgs = append(evalArgs, arg)
+		} else {
+			queries = append(queries, arg)
+		}
+	}
+	if len(evalArgs) == 0 || len(queries) > 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	ev.DryRun = !build
+	_, roots, err := evaluate(evalArgs, &ev)
+	if err != nil {
+		exitError(err)
+	}
+
+	/* without target the outputs of the result are its direct dependencies */
+	var target *types.PathExpr
+	root := types.PathExpr{Depends: roots}
+	if len(queries) == 1 {
+		p, ok := findPath(roots, queries[0])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "no output named %s\n", queries[0])
+			os.Exit(1)
+		}
+		target, root = &p, p
+	}
+	direct, transitive := dependencies(root)
+
+	if jsonOutput {
+		result := make(map[string]any)
+		if target != nil {
+			result = depsJSON(*target)
+		}
+		for key, list := range map[string][]types.PathExpr{"direct": direct, "transitive": transitive} {
+			entries := make([]any, len(list))
+			for i, p := range list {
+				entries[i] = depsJSON(p)
+			}
+			result[key] = entries
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		enc.Encode(result)
+		return
+	}
+	for _, list := range []struct {
+		kind  string
+		paths []types.PathExpr
+	}{{"direct", direct}, {"transitive", transitive}} {
+		for _, p := range list.paths {
+			name, hash := pathLabel(p)
+			if hash == "" {
+				hash = "-"
+			}
+			fmt.Printf("%-10s %-20s %-32s %s\n", list.kind, name, hash, p.Pos())
+		}
+	}
+}
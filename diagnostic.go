@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* format of errors and warnings, text or json */
+var errorFormat = "text"
+
+/* structured error or warning, printed as a line of JSON with --error-format json */
+type diagnostic struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Col      int    `json:"col,omitempty"`    /* 1-based */
+	EndCol   int    `json:"endCol,omitempty"` /* last column of the offending token if known */
+	Severity string `json:"severity"`         /* error or warning */
+	Code     string `json:"code"`             /* parse, eval, build or error if the cause is unknown */
+	Message  string `json:"message"`
+}
+
+/* located fills in the position of `d` from `pos` */
+func (d diagnostic) located(pos types.Position) diagnostic {
+	d.File = pos.Path()
+	if pos.Line > 0 {
+		d.Line = pos.Line
+		d.Col = pos.Column()
+	}
+	return d
+}
+
+/* diagnose converts `err` to diagnostics, joined errors result in one diagnostic each */
+func diagnose(err error) []diagnostic {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var diags []diagnostic
+		for _, e := range joined.Unwrap() {
+			diags = append(diags, diagnose(e)...)
+		}
+		return diags
+	}
+	var (
+		buildErr *types.BuildError
+		parseErr *types.ParseError
+		evalErr  *types.EvalError
+	)
+	switch {
+	case errors.As(err, &buildErr):
+		return []diagnostic{diagnostic{Severity: "error", Code: "build", Message: buildErr.Message()}.located(buildErr.Position)}
+	case errors.As(err, &parseErr):
+		d := diagnostic{Severity: "error", Code: "parse", Message: parseErr.Err.Error()}.located(parseErr.Position)
+		if parseErr.End > parseErr.Offset {
+			d.EndCol = parseErr.End
+		}
+		return []diagnostic{d}
+	case errors.As(err, &evalErr):
+		return []diagnostic{diagnostic{Severity: "error", Code: "eval", Message: evalErr.Err.Error()}.located(evalErr.Position)}
+	}
+	return []diagnostic{{Severity: "error", Code: "error", Message: err.Error()}}
+}
+
+/* printDiagnostics prints `diags` to stderr as JSON lines */
+func printDiagnostics(diags ...diagnostic) {
+	enc := json.NewEncoder(os.Stderr)
+	for _, d := range diags {
+		enc.Encode(d)
+	}
+}
+
+/* printWarning prints `warn` in the format of --error-format */
+func printWarning(warn types.Warning) {
+	if errorFormat == "json" {
+		printDiagnostics(diagnostic{Severity: "warning", Code: "eval", Message: warn.Message}.located(warn.Position))
+		return
+	}
+	fmt.Fprintln(os.Stderr, warn)
+}
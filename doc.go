@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+func docMain(args []string) {
+	flags := flag.NewFlagSet("doc", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon doc <file.zon>\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	ast, err := parser.ParseFile(types.PathExpr{Position: types.Position{Filename: "<commandline>"}, Name: flags.Arg(0)})
+	if err != nil {
+		exitError(err)
+	}
+
+	for i, entry := range types.Documentation(ast) {
+		if i > 0 {
+			fmt.Println()
+		}
+		if entry.Fn {
+			fmt.Printf("%s(%s)\n", entry.Name, strings.Join(entry.Args, ", "))
+		} else {
+			fmt.Println(entry.Name)
+		}
+		for _, line := range strings.Split(entry.Doc, "\n") {
+			if line != "" {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* shellQuote quotes `value` in single quotes, so it is taken literally by sh and dotenv-parsers */
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func envMain(args []string) {
+	var (
+		ev       types.Evaluator
+		outfile  string
+		format   string
+		encoding string
+		export   bool
+	)
+
+	flags := flag.NewFlagSet("env", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon env [options] <file.zon> [key=value ...]\n")
+		flags.PrintDefaults()
+	}
+	evaluatorFlags(flags, &ev)
+	flags.StringVarP(&outfile, "output", "o", "", "write variables to `file` instead of stdout")
+	flags.StringVar(&format, "format", "shell", "`format` of variables, shell (quoted) or docker (unquoted)")
+	flags.StringVar(&encoding, "encoding", "plain", "`encoding` of nested values, plain, json or prefix")
+	flags.BoolVar(&export, "export", false, "prefix every variable with export, only with --format shell")
+	flags.Parse(args)
+
+	if format != "shell" && format != "docker" {
+		fmt.Fprintf(os.Stderr, "invalid --format: `%s`, expected shell or docker\n", format)
+		os.Exit(1)
+	}
+	if export && format != "shell" {
+		fmt.Fprintf(os.Stderr, "--export is only supported with --format shell, not %s\n", format)
+		os.Exit(1)
+	}
+
+	res, _, err := evaluate(flags.Args(), &ev)
+	if err != nil {
+		exitError(err)
+	}
+
+	mapval, ok := res.(types.MapValue)
+	if !ok {
+		fmt.Printf("%s: unable to export non-map: %T\n", res.Pos(), res)
+		os.Exit(1)
+	}
+
+	var vars []string
+	for key, value := range mapval.Values {
+		enc, err := types.EncodeVariable(key, value, encoding)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		vars = append(vars, enc...)
+	}
+	slices.Sort(vars)
+
+	var out io.Writer = os.Stdout
+	if outfile != "" {
+		file, err := os.Create(outfile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	for _, variable := range vars {
+		key, value, _ := strings.Cut(variable, "=")
+		if export {
+			fmt.Fprint(out, "export ")
+		}
+		switch format {
+		case "shell":
+			fmt.Fprintf(out, "%s=%s\n", key, shellQuote(value))
+		case "docker":
+			if strings.ContainsRune(value, '\n') {
+				fmt.Fprintf(os.Stderr, "%s: unable to write multiline value in docker-format\n", key)
+				os.Exit(1)
+			}
+			fmt.Fprintf(out, "%s=%s\n", key, value)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* lastUsed returns when store-entry `name` was used last, by its index-entry or its modification time */
+func lastUsed(cachedir string, index map[string]types.IndexEntry, name string) time.Time {
+	if entry, ok := index[name]; ok {
+		if !entry.Used.IsZero() {
+			return entry.Used
+		}
+		return entry.Built
+	}
+	if info, err := os.Stat(path.Join(cachedir, name)); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+/* liveEntries returns the store-entries reachable from a result-symlink or an output of the last evaluation of `ev` */
+func liveEntries(ev *types.Evaluator, index map[string]types.IndexEntry) (map[string]bool, error) {
+	roots, err := types.Roots(ev.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range ev.Outputs {
+		roots[path.Base(info.Path)] = true
+	}
+	live := make(map[string]bool)
+	for root := range roots {
+		for _, name := range closure(index, root) {
+			live[name] = true
+		}
+	}
+	return live, nil
+}
+
+/* evict removes the least recently used store-entries until the store is not larger than `max`,
+ * entries reachable from a result-symlink or used by the last evaluation of `ev` are kept,
+ * with `dryRun` they are only listed and without `yes` the removal is confirmed first */
+func evict(ev *types.Evaluator, max int64, dryRun bool, yes bool) error {
+	entries, err := os.ReadDir(ev.CacheDir)
+	if err != nil {
+		return err
+	}
+	index, err := types.ReadIndex(ev.CacheDir)
+	if err != nil {
+		return err
+	}
+	keep, err := liveEntries(ev, index)
+	if err != nil {
+		return err
+	}
+
+	var (
+		total      int64
+		candidates []string
+		sizes      = make(map[string]int64)
+	)
+	for _, entry := range entries {
+		/* impure outputs are pruned when they are built, hidden entries are temporary */
+		if entry.Name() == types.ImpureDir || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		size := diskUsage(path.Join(ev.CacheDir, entry.Name()))
+		total += size
+		sizes[entry.Name()] = size
+		if !keep[entry.Name()] {
+			candidates = append(candidates, entry.Name())
+		}
+	}
+	slices.SortFunc(candidates, func(a, b string) int {
+		return lastUsed(ev.CacheDir, index, a).Compare(lastUsed(ev.CacheDir, index, b))
+	})
+
+	var remove, described []string
+	for _, name := range candidates {
+		if total <= max {
+			break
+		}
+		remove = append(remove, name)
+		described = append(described, fmt.Sprintf("%s (%s)", name, formatSize(sizes[name])))
+		total -= sizes[name]
+	}
+	if dryRun {
+		for _, desc := range described {
+			fmt.Fprintf(os.Stderr, "would evict %s\n", desc)
+		}
+		return nil
+	}
+	if !confirm("evicted", described, yes) {
+		return nil
+	}
+	for i, name := range remove {
+		fmt.Fprintf(os.Stderr, "evict %s\n", described[i])
+		if err := os.RemoveAll(path.Join(ev.CacheDir, name)); err != nil {
+			return err
+		}
+		types.RemoveIndex(ev.CacheDir, name)
+	}
+	if total > max {
+		fmt.Fprintf(os.Stderr, "store is %s after eviction, the remaining entries are in use\n", formatSize(total))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+func generationsMain(args []string) {
+	var resultName string
+
+	flags := flag.NewFlagSet("generations", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon generations [options]\n")
+		flags.PrintDefaults()
+	}
+	flags.StringVarP(&resultName, "output", "o", "result", "name of result-symlink")
+	flags.Parse(args)
+
+	gens, err := types.Generations(resultName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	current := types.CurrentGeneration(resultName)
+	for _, gen := range gens {
+		marker := " "
+		if gen.Number == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %4d  %s  %s\n", marker, gen.Number, gen.Time.Format("2006-01-02 15:04:05"), gen.Target)
+	}
+}
+
+func rollbackMain(args []string) {
+	var (
+		resultName string
+		target     int
+	)
+
+	flags := flag.NewFlagSet("rollback", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon rollback [options]\n")
+		flags.PrintDefaults()
+	}
+	flags.StringVarP(&resultName, "output", "o", "result", "name of result-symlink")
+	flags.IntVar(&target, "to", 0, "switch to generation `number` instead of the previous one")
+	flags.Parse(args)
+
+	gens, err := types.Generations(resultName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if target == 0 {
+		current := types.CurrentGeneration(resultName)
+		for _, gen := range gens {
+			if gen.Number < current || current == -1 {
+				target = gen.Number
+			}
+		}
+		if target == 0 {
+			fmt.Fprintf(os.Stderr, "no generation before %d\n", current)
+			os.Exit(1)
+		}
+	}
+
+	found := false
+	for _, gen := range gens {
+		if gen.Number == target {
+			found = true
+		}
+	}
+	if !found {
+		fmt.Fprintf(os.Stderr, "no such generation: %d\n", target)
+		os.Exit(1)
+	}
+
+	if err := types.SwitchGeneration(resultName, target); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("switched %s to generation %d\n", resultName, target)
+}
@@ -2,4 +2,12 @@ module github.com/friedelschoen/zon
 
 go 1.23.4
 
-require github.com/spf13/pflag v1.0.5
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/klauspost/compress v1.17.11
+	github.com/spf13/pflag v1.0.5
+	github.com/zeebo/blake3 v0.2.4
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/klauspost/cpuid/v2 v2.0.12 // indirect
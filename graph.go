@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* pathLabel returns the output-name and hash of a store path, or its base name if it was not produced by an output */
+func pathLabel(p types.PathExpr) (string, string) {
+	base := path.Base(p.Name)
+	if p.OutputName == "" {
+		return base, ""
+	}
+	hash, _, _ := strings.Cut(base, "-")
+	return p.OutputName, hash
+}
+
+/* writeGraph writes the dependency graph of `roots` in DOT-format */
+func writeGraph(w io.Writer, roots []types.PathExpr) {
+	seen := make(map[string]bool)
+	var walk func(p types.PathExpr)
+	walk = func(p types.PathExpr) {
+		if seen[p.Name] {
+			return
+		}
+		seen[p.Name] = true
+		name, hash := pathLabel(p)
+		fmt.Fprintf(w, "\t%q [label=%q, tooltip=%q];\n", p.Name, name, hash)
+		for _, dep := range p.Depends {
+			fmt.Fprintf(w, "\t%q -> %q;\n", p.Name, dep.Name)
+			walk(dep)
+		}
+	}
+
+	fmt.Fprintln(w, "digraph zon {")
+	for _, root := range roots {
+		walk(root)
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+func lexMain(args []string) {
+	flags := flag.NewFlagSet("lex", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon lex [options] <file.zon>\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	filename := flags.Arg(0)
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	s := parser.NewScanner(file)
+	for {
+		err := s.Next()
+		pos := types.Position{Filename: filename, Line: s.Linenr, Offset: s.Start}
+		if err != nil {
+			exitError(&types.ParseError{Position: pos, End: s.End, Err: err})
+		}
+		span := fmt.Sprintf("%s-%d", pos.Pos(), s.End)
+		fmt.Printf("%-20s %-20s %-18s %s\n", span, s.Token, s.State(), strconv.Quote(s.Text()))
+		if s.Token == parser.TokenEOF {
+			break
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+func lockMain(args []string) {
+	var (
+		ev     types.Evaluator
+		update bool
+	)
+
+	flags := flag.NewFlagSet("lock", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon lock [options] <file.zon> [key=value ...] [input ...]\n")
+		flags.PrintDefaults()
+	}
+	evaluatorFlags(flags, &ev)
+	flags.BoolVarP(&update, "update", "u", false, "refresh the given inputs by name or url, or all inputs if none are given")
+	flags.Parse(args)
+
+	/* the first argument is the file, following are inputs */
+	var evalArgs []string
+	ev.Lock = &types.Lock{}
+	for _, arg := range flags.Args() {
+		if strings.Contains(arg, "=") || len(evalArgs) == 0 {
+			evalArgs = append(evalArgs, arg)
+		} else {
+			ev.Lock.Update = append(ev.Lock.Update, arg)
+		}
+	}
+	if len(ev.Lock.Update) > 0 && !update {
+		fmt.Fprintf(os.Stderr, "inputs given without --update\n")
+		os.Exit(1)
+	}
+	ev.Lock.UpdateAll = update && len(ev.Lock.Update) == 0
+
+	/* only inputs are fetched, outputs are not built */
+	ev.DryRun = true
+	if _, _, err := evaluate(evalArgs, &ev); err != nil {
+		exitError(err)
+	}
+	for _, entry := range ev.Lock.Inputs {
+		rev := entry.Hash
+		if entry.Rev != "" {
+			rev = entry.Rev
+		}
+		fmt.Printf("%s: %s %s\n", entry.Name, entry.URL, rev)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+var logCommands = map[string]func(args []string){
+	"gc":   logGCMain,
+	"show": logShowMain,
+}
+
+func logMain(args []string) {
+	if len(args) > 0 {
+		if cmd, ok := logCommands[args[0]]; ok {
+			cmd(args[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "usage: zon log gc [options]\n       zon log show [options] <name|hash>\n")
+	os.Exit(1)
+}
+
+/* openLog opens the log of store-entry `storename` in `logdir`, which may be compressed */
+func openLog(logdir string, storename string) (io.ReadCloser, error) {
+	logpath := path.Join(logdir, storename+".log")
+	if file, err := os.Open(logpath); err == nil {
+		return file, nil
+	}
+	file, err := os.Open(logpath + ".gz")
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{zr, file}, nil
+}
+
+func logShowMain(args []string) {
+	var ev types.Evaluator
+
+	flags := flag.NewFlagSet("log show", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon log show [options] <name|hash>\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	matches, err := types.LookupIndex(ev.CacheDir, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(matches) == 0 {
+		fmt.Fprintf(os.Stderr, "no store-entry named %s\n", flags.Arg(0))
+		os.Exit(1)
+	}
+
+	/* the latest entry */
+	log, err := openLog(ev.LogDir, matches[0].StoreName())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer log.Close()
+	io.Copy(os.Stdout, log)
+}
+
+func logGCMain(args []string) {
+	var (
+		ev      types.Evaluator
+		maxAge  string
+		maxSize string
+		dryRun  bool
+		yes     bool
+	)
+
+	flags := flag.NewFlagSet("log gc", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon log gc [options]\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.StringVar(&maxAge, "max-log-age", "", "delete logs older than `age`, e.g. 30d")
+	flags.StringVar(&maxSize, "max-log-size", "", "compress logs larger than `size`, e.g. 10M, and delete the oldest logs until all of them together are not larger than it")
+	flags.BoolVarP(&dryRun, "dry-run", "n", false, "list the logs which would be deleted or compressed")
+	flags.BoolVarP(&yes, "yes", "y", false, "delete logs without asking for confirmation")
+	flags.Parse(args)
+
+	var (
+		age  time.Duration
+		size int64
+		err  error
+	)
+	if maxAge != "" {
+		if age, err = parseAge(maxAge); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if maxSize != "" {
+		if size, err = parseSize(maxSize); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	entries, err := os.ReadDir(ev.LogDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	type logFile struct {
+		name    string
+		modtime time.Time
+		size    int64
+	}
+	var (
+		remove, reasons []string
+		kept            []logFile
+		compress        []int /* indices in kept */
+	)
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".gz")
+		hashstr, ok := strings.CutSuffix(name, ".log")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		var reason string
+		if !storeExists(ev.CacheDir, hashstr) {
+			reason = "orphaned"
+		} else if age > 0 && time.Since(info.ModTime()) > age {
+			reason = "older than " + maxAge
+		} else {
+			if size > 0 && info.Size() > size && path.Ext(entry.Name()) != ".gz" {
+				compress = append(compress, len(kept))
+			}
+			kept = append(kept, logFile{entry.Name(), info.ModTime(), info.Size()})
+			continue
+		}
+		remove = append(remove, entry.Name())
+		reasons = append(reasons, fmt.Sprintf("%s (%s)", entry.Name(), reason))
+	}
+
+	for _, i := range compress {
+		if dryRun {
+			fmt.Printf("would compress %s\n", kept[i].name)
+			continue
+		}
+		fmt.Printf("compress %s\n", kept[i].name)
+		filename := path.Join(ev.LogDir, kept[i].name)
+		if err := types.CompressLog(filename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if info, err := os.Stat(filename + ".gz"); err == nil {
+			kept[i].name += ".gz"
+			kept[i].size = info.Size()
+		}
+	}
+
+	/* logs still too large after compressing are deleted oldest first */
+	if size > 0 {
+		var total int64
+		for _, log := range kept {
+			total += log.size
+		}
+		slices.SortFunc(kept, func(a, b logFile) int {
+			return a.modtime.Compare(b.modtime)
+		})
+		for _, log := range kept {
+			if total <= size {
+				break
+			}
+			remove = append(remove, log.name)
+			reasons = append(reasons, fmt.Sprintf("%s (logs larger than %s)", log.name, maxSize))
+			total -= log.size
+		}
+	}
+
+	if dryRun {
+		for _, desc := range reasons {
+			fmt.Printf("would delete %s\n", desc)
+		}
+		return
+	}
+	if confirm("deleted", reasons, yes) {
+		for i, name := range remove {
+			fmt.Printf("delete %s\n", reasons[i])
+			os.Remove(path.Join(ev.LogDir, name))
+		}
+	}
+}
@@ -2,55 +2,126 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
-	"slices"
+	"path/filepath"
+	"runtime/pprof"
 	"strings"
+	"time"
 
 	"github.com/friedelschoen/zon/parser"
 	"github.com/friedelschoen/zon/types"
 	flag "github.com/spf13/pflag"
 )
 
-func PrintPathTree(p types.PathExpr, indent string) {
-	fmt.Println(indent + "- " + path.Base(p.Name))
+/* attributes to override, in the form of attr.path=expr */
+var overrides []string
 
-	for _, dep := range p.Depends {
-		PrintPathTree(dep, indent+"  ")
-	}
-}
+/* fail if the evaluation emitted warnings */
+var denyWarnings bool
 
-func main() {
-	var (
-		ev         types.Evaluator
-		resultName string
-		noResult   bool
-		jsonOutput bool
-		cleanup    bool
-	)
+/* do not print warnings, set by bench after the first run */
+var quietWarnings bool
 
-	ev.ParseFile = parser.ParseFile
+var commands = map[string]func(args []string){
+	"ast":         astMain,
+	"bench":       benchMain,
+	"bundle":      bundleMain,
+	"closure":     closureMain,
+	"copy":        copyMain,
+	"deps":        depsMain,
+	"doc":         docMain,
+	"env":         envMain,
+	"export":      exportMain,
+	"generations": generationsMain,
+	"import":      importMain,
+	"lex":         lexMain,
+	"lock":        lockMain,
+	"log":         logMain,
+	"oci":         ociMain,
+	"plan":        planMain,
+	"rollback":    rollbackMain,
+	"store":       storeMain,
+	"targets":     targetsMain,
+	"why":         whyMain,
+	"why-depends": whyDependsMain,
+}
 
-	flag.BoolVarP(&ev.Force, "force", "f", false, "force building all outputs")
-	flag.BoolVarP(&ev.DryRun, "dry", "d", false, "do not build anything")
-	flag.StringVarP(&ev.CacheDir, "cache", "c", "cache/store", "destination of outputs")
-	flag.StringVarP(&ev.LogDir, "log", "l", "cache/log", "destination of logs of outputs")
-	flag.StringVarP(&resultName, "output", "o", "result", "name of result-symlink")
-	flag.BoolVar(&noResult, "no-result", false, "disables creation of result-symlink")
-	flag.BoolVarP(&ev.Serial, "serial", "s", false, "do not build output asynchronous")
-	flag.StringVar(&ev.Interpreter, "interpreter", "sh", "default interpreter for output")
-	flag.BoolVar(&ev.NoEvalOutput, "no-eval-output", false, "skip evaluation of output")
-	flag.BoolVar(&jsonOutput, "json", false, "print result as JSON, implies --no-result")
-	flag.BoolVarP(&cleanup, "clean", "g", false, "clean orphaned results, not used by this build")
-	flag.Parse()
+/* storeFlags registers the flags locating the store and logs */
+func storeFlags(flags *flag.FlagSet, ev *types.Evaluator) {
+	ev.CacheDir = config.CacheDir
+	flags.VarP(absPath{&ev.CacheDir}, "cache", "c", "destination of outputs, made absolute")
+	flags.StringVarP(&ev.LogDir, "log", "l", config.LogDir, "destination of logs of outputs")
+}
 
-	if jsonOutput {
-		noResult = true
+/* evaluatorFlags registers the flags shared by every command which evaluates a file */
+func evaluatorFlags(flags *flag.FlagSet, ev *types.Evaluator) {
+	storeFlags(flags, ev)
+	flags.BoolVarP(&ev.Force, "force", "f", false, "force building all outputs")
+	flags.BoolVarP(&ev.DryRun, "dry", "d", false, "do not build anything")
+	flags.BoolVarP(&ev.Serial, "serial", "s", false, "do not build output asynchronous")
+	flags.IntVarP(&ev.Jobs, "jobs", "j", config.Jobs, "maximum of concurrent builds, 0 for unlimited")
+	flags.BoolVarP(&ev.KeepGoing, "keep-going", "k", config.KeepGoing, "let other builders finish when one failed instead of killing them")
+	flags.StringVar(&ev.Interpreter, "interpreter", config.Interpreter, "default interpreter for output")
+	flags.StringSliceVar(&ev.Substituters, "substituters", config.Substituters, "binary caches to query for missing outputs")
+	flags.StringVar(&ev.HashAlgorithm, "hash-algorithm", config.HashAlgorithm, "hash-algorithm of outputs and fetched inputs: sha256, sha512 or blake3")
+	flags.IntVar(&ev.StoreKeyLength, "store-key-length", config.StoreKeyLength, "characters of the base32-hash in store-names, 32 if 0")
+	flags.StringVar(&ev.BuildUser, "build-user", config.BuildUser, "`user` or uid:gid builders run as if zon runs as root, nobody by default, root to disable")
+	flags.StringVar(&ev.HTTP.Proxy, "proxy", config.Proxy, "proxy of fetchers and substituters, HTTP_PROXY and HTTPS_PROXY if empty")
+	flags.StringVar(&ev.HTTP.Netrc, "netrc", config.Netrc, "netrc-file with credentials of fetchers and substituters, ~/.netrc if empty")
+	ev.HTTP.Hosts = config.Hosts
+	flags.BoolVar(&ev.NoEvalOutput, "no-eval-output", false, "skip evaluation of output")
+	flags.StringVar(&ev.Secrets.File, "secrets", config.Secrets, "read secrets of builders from `file` of name=value lines")
+	flags.StringVar(&ev.Secrets.Command, "secrets-command", config.SecretsCommand, "read missing secrets from the output of `command` called with the name of the secret")
+	flags.StringArrayVar(&ev.Redact, "redact", config.Redact, "mask matches of `regex` in build logs, can be repeated")
+	flags.BoolVar(&ev.LegacyEnv, "legacy-env", config.LegacyEnv, "pass every attribute of an output to the builder instead of only `env`")
+	flags.BoolVar(&ev.Check, "check", false, "rebuild cached outputs and fail if they differ from the stored ones")
+	flags.BoolVar(&ev.Verify, "verify", config.Verify, "check the content of cached outputs and rebuild corrupt or incomplete ones")
+	flags.BoolVar(&ev.Sandbox, "sandbox", config.Sandbox, "build with a fixed environment instead of inheriting it")
+	flags.StringSliceVar(&ev.Strip, "strip", config.Strip, "remove files matching `patterns` from every output, e.g. *.la,*.pyc")
+	if err := (sizeValue{&ev.CompressLogs}).Set(config.CompressLogs); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid compress-logs in configuration: %v\n", err)
+		os.Exit(1)
 	}
+	flags.Var(sizeValue{&ev.CompressLogs}, "compress-logs", "gzip logs of successful builds larger than `size`, 0 to disable")
+	flags.IntVar(&ev.LogTailLines, "log-tail-lines", 20, "print the last `n` lines of the log of a failed build, 0 to disable")
+	flags.BoolVar(&ev.Strict, "strict", false, "reject unknown output-attributes, implicit coercions to string and impure constructs")
+	flags.BoolVar(&ev.AllowImpure, "allow-impure", false, "allow impure outputs and unpinned fetches with --strict")
+	flags.BoolVar(&denyWarnings, "deny-warnings", false, "fail if the evaluation emitted warnings")
+	flags.StringArrayVar(&overrides, "override", nil, "replace the attribute at `attr.path=expr` before evaluating")
+	flags.StringVar(&errorFormat, "error-format", "text", "print errors and warnings as `format` text or json, one object per line")
+}
 
-	if noResult {
-		resultName = ""
+/* printError prints `err` and returns the exit code, 3 if a builder failed and 1 otherwise */
+func printError(err error) int {
+	if errorFormat == "json" {
+		printDiagnostics(diagnose(err)...)
+	} else {
+		fmt.Println(err)
+	}
+	var buildErr *types.BuildError
+	if errors.As(err, &buildErr) {
+		return 3
+	}
+	return 1
+}
+
+/* exitError prints `err` and exits, with 3 if a builder failed and 1 otherwise */
+func exitError(err error) {
+	os.Exit(printError(err))
+}
+
+/* evaluate parses and resolves the file named in `args`, other arguments in the form of key=value are put in scope */
+func evaluate(args []string, ev *types.Evaluator) (types.Value, []types.PathExpr, error) {
+	ev.ParseFile = parser.ParseFile
+	ev.Registry = config.Registry
+
+	if errorFormat != "text" && errorFormat != "json" {
+		format := errorFormat
+		errorFormat = "text"
+		return nil, nil, fmt.Errorf("invalid --error-format: `%s`, expected text or json", format)
 	}
 
 	if ev.DryRun && ev.Force {
@@ -59,64 +130,323 @@ func main() {
 
 	filename := ""
 	scope := make(types.Scope)
-	for _, arg := range flag.Args() {
+	for _, arg := range args {
 		if name, value, ok := strings.Cut(arg, "="); ok {
 			scope[name] = types.Variable{Expr: types.StringConstant(value, "<commandline>"), Scope: make(types.Scope)}
 		} else if filename == "" {
 			filename = arg
 		} else {
-			fmt.Fprintf(os.Stderr, "obsolete argument: `%s`\n", arg)
-			os.Exit(1)
+			return nil, nil, fmt.Errorf("obsolete argument: `%s`", arg)
 		}
 	}
 
 	if filename == "" {
-		fmt.Fprintf(os.Stderr, "no file provided\n")
-		os.Exit(1)
+		return nil, nil, fmt.Errorf("no file provided")
 	}
+	ev.RootFile, _ = filepath.Abs(filename)
 
 	ast, err := parser.ParseFile(types.PathExpr{Position: types.Position{Filename: "<commandline>"}, Name: filename})
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, nil, err
+	}
+
+	for _, over := range overrides {
+		attrpath, src, ok := strings.Cut(over, "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid override: `%s`, expected attr.path=expr", over)
+		}
+		value, err := parser.ParseString(src, "<override>")
+		if err != nil {
+			return nil, nil, err
+		}
+		ast, err = types.Override(ast, strings.Split(attrpath, "."), value)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if ev.Lock == nil {
+		ev.Lock = &types.Lock{}
+	}
+	lock, err := types.ReadLock(path.Join(path.Dir(filename), "zon.lock"))
+	if err != nil {
+		return nil, nil, err
+	}
+	lock.Update, lock.UpdateAll = ev.Lock.Update, ev.Lock.UpdateAll
+	ev.Lock = lock
+
+	ev.CacheDir, err = filepath.Abs(ev.CacheDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	ev.LogDir, err = filepath.Abs(ev.LogDir)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	if !ev.DryRun {
 		os.MkdirAll(ev.CacheDir, 0755)
 		os.MkdirAll(ev.LogDir, 0755)
 	}
-	res, deps, err := ast.Resolve(scope, &ev)
+	res, deps, err := ev.Resolve(ast, types.FileScope(scope, ev.RootFile))
+	for _, warn := range ev.Warnings {
+		if !quietWarnings {
+			printWarning(warn)
+		}
+	}
 	if err != nil {
-		fmt.Println(err)
+		return nil, nil, err
+	}
+	if denyWarnings && len(ev.Warnings) > 0 {
+		return nil, nil, fmt.Errorf("%d warnings emitted with --deny-warnings", len(ev.Warnings))
+	}
+	return res, deps, ev.Lock.Write()
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == types.SandboxCommand {
+		types.SandboxMain(os.Args[2:])
+		return
+	}
+
+	if err := loadConfig(&config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	_ = deps
-	// for _, d := range deps {
-	// 	PrintPathTree(d, "")
-	// }
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
+	os.Exit(run())
+}
+
+/* run evaluates and builds the file given on the commandline, it returns the exit code */
+func run() int {
+	var (
+		ev         types.Evaluator
+		resultName string
+		noResult   bool
+		jsonOutput string
+		sortKeys   bool
+		cleanup    bool
+		dryExit    bool
+		printTree  bool
+		graphFile  string
+		timings    bool
+		timingFile string
+		profFile   string
+		traceFile  string
+		statsFile  string
+		tree       = treePrinter{out: os.Stdout}
+		cleanOpts  cleanOptions
+		olderThan  string
+		maxStore   string
+		maxSize    int64
+		notify     = notifier{ev: &ev}
+		code       int /* exit code if nothing failed */
+	)
+
+	evaluatorFlags(flag.CommandLine, &ev)
+	flag.StringVarP(&resultName, "output", "o", "result", "name of result-symlink")
+	flag.BoolVar(&noResult, "no-result", false, "disables creation of result-symlink")
+	flag.StringVar(&jsonOutput, "json", "", "print result as JSON, `mode` full includes metadata of every output, implies --no-result")
+	flag.Lookup("json").NoOptDefVal = "value"
+	flag.BoolVar(&sortKeys, "sort-keys", config.SortKeys, "print the keys of maps sorted with --json instead of in the order they are defined")
+	flag.BoolVar(&dryExit, "dry-exit-code", false, "exit with 2 if --dry reports outputs which would be built")
+	flag.BoolVar(&printTree, "tree", false, "print the dependency tree of the result")
+	flag.StringVar(&graphFile, "graph", "", "write the dependency graph in DOT-format to `file`")
+	flag.StringVar(&profFile, "profile", "", "write a pprof-profile of the evaluation to `file` and print the most expensive expressions")
+	flag.StringVar(&traceFile, "trace-eval", "", "write every resolved expression with its value-type and duration to `file`, stderr if omitted, implies --serial")
+	flag.Lookup("trace-eval").NoOptDefVal = "-"
+	flag.StringVar(&statsFile, "stats-json", "", "write statistics of this run as JSON to `file`")
+	flag.BoolVar(&timings, "timings", false, "print the slowest outputs and the critical path")
+	flag.StringVar(&timingFile, "timings-json", "", "write the build time of every output as JSON to `file`")
+	flag.IntVar(&tree.maxDepth, "tree-depth", 0, "limit the dependency tree to `depth` levels, unlimited if 0")
+	flag.BoolVar(&tree.sizes, "tree-size", false, "print the size of every path in the dependency tree")
+	flag.BoolVarP(&cleanup, "clean", "g", false, "clean orphaned results, not used by this build")
+	flag.BoolVar(&cleanOpts.dryRun, "clean-dry-run", false, "list orphaned results which would be cleaned")
+	flag.StringVar(&olderThan, "clean-older-than", "", "only clean results older than `age`, e.g. 30d")
+	flag.BoolVarP(&cleanOpts.yes, "yes", "y", false, "clean and evict without asking for confirmation")
+	flag.IntVar(&cleanOpts.keepLast, "keep-last", 0, "keep the last `n` results of every output-name when cleaning")
+	flag.StringVar(&notify.command, "notify-cmd", config.NotifyCmd, "run `command` with a JSON-event on stdin when the run finished or a builder failed")
+	flag.StringVar(&notify.webhook, "notify-webhook", config.NotifyWebhook, "post a JSON-event to `url` when the run finished or a builder failed")
+	flag.StringVar(&maxStore, "max-store-size", config.MaxStoreSize, "evict the least recently used entries not reachable from a result if the store is larger than `size`, e.g. 20G")
+	flag.Parse()
+
+	if maxStore != "" {
+		var err error
+		if maxSize, err = parseSize(maxStore); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if olderThan != "" {
+		var err error
+		if cleanOpts.olderThan, err = parseAge(olderThan); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	switch jsonOutput {
+	case "":
+	case "value", "full":
+		noResult = true
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --json mode: `%s`, expected value or full\n", jsonOutput)
+		return 1
+	}
+
+	if noResult || ev.DryRun {
+		resultName = ""
+	}
+
+	if profFile != "" {
+		file, err := os.Create(profFile)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		ev.Profile = types.NewProfile()
+	}
 
-	if cleanup {
-		cwd, _ := os.Getwd()
-		entries, err := os.ReadDir(path.Join(cwd, ev.CacheDir))
+	switch traceFile {
+	case "":
+	case "-":
+		ev.Trace = types.NewTrace(os.Stderr)
+	default:
+		file, err := os.Create(traceFile)
 		if err != nil {
 			fmt.Println(err)
-			entries = nil
+			return 1
+		}
+		defer file.Close()
+		ev.Trace = types.NewTrace(file)
+	}
+	if ev.Trace != nil {
+		/* the depth of concurrently resolved expressions would be mixed up */
+		ev.Serial = true
+	}
+
+	if notify.enabled() {
+		ev.OnFailure = notify.failed
+	}
+
+	start := time.Now()
+	res, deps, err := evaluate(flag.Args(), &ev)
+
+	if notify.enabled() {
+		notify.finished(err, time.Since(start))
+	}
+
+	if statsFile != "" {
+		if err := writeStats(statsFile, &ev, time.Since(start)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
 		}
-		for _, entry := range entries {
-			if !slices.Contains(ev.Outputs, entry.Name()) {
-				fmt.Printf("clean %s\n", entry.Name())
-				os.RemoveAll(path.Join(cwd, ev.CacheDir, entry.Name()))
+	}
+
+	if ev.Profile != nil {
+		pprof.StopCPUProfile()
+		ev.Profile.WriteSummary(os.Stderr, 20)
+	}
+
+	if err != nil {
+		return printError(err)
+	}
+
+	if !ev.DryRun {
+		ev.RecordOrigins(res)
+	}
+
+	if ev.DryRun {
+		var cached, build int
+		for _, info := range uniqueOutputs(&ev) {
+			if info.Cached {
+				fmt.Fprintf(os.Stderr, "cached       %s\n", path.Base(info.Path))
+				cached++
+			} else {
+				fmt.Fprintf(os.Stderr, "would build  %s\n", path.Base(info.Path))
+				build++
 			}
 		}
+		fmt.Fprintf(os.Stderr, "%d cached, %d would build\n", cached, build)
+		if dryExit && build > 0 {
+			code = 2
+		}
+	}
+
+	if printTree {
+		tree.PrintPathTree(deps)
+	}
+
+	if timings {
+		printTimings(os.Stderr, uniqueOutputs(&ev), 10)
+	}
+
+	if timingFile != "" {
+		content, err := json.MarshalIndent(timingsJSON(uniqueOutputs(&ev)), "", "\t")
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if err := os.WriteFile(timingFile, content, 0644); err != nil {
+			fmt.Println(err)
+			return 1
+		}
 	}
 
-	if jsonOutput {
+	if graphFile != "" {
+		file, err := os.Create(graphFile)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		writeGraph(file, deps)
+		file.Close()
+	}
+
+	if cleanup || cleanOpts.dryRun {
+		if err := clean(&ev, cleanOpts); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	if jsonOutput != "" {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "\t")
-		enc.Encode(res.JSON())
+		result := res.JSON()
+		if sortKeys {
+			result = types.SortKeys(result)
+		}
+		if jsonOutput == "full" {
+			outputs := make([]any, len(ev.Outputs))
+			for i, info := range ev.Outputs {
+				outputs[i] = info.JSON()
+			}
+			enc.Encode(map[string]any{
+				"result":  result,
+				"outputs": outputs,
+			})
+		} else {
+			enc.Encode(result)
+		}
 	} else if err := res.Link(resultName); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return printError(err)
+	}
+
+	if maxSize > 0 {
+		if err := evict(&ev, maxSize, ev.DryRun, cleanOpts.yes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	}
+	return code
 }
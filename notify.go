@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"time"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* notifier sends events of a run as JSON to a command and a webhook */
+type notifier struct {
+	command string /* run by the interpreter with the event on stdin */
+	webhook string /* url the event is posted to */
+	ev      *types.Evaluator
+}
+
+func (n notifier) enabled() bool {
+	return n.command != "" || n.webhook != ""
+}
+
+/* send delivers `event` to the command and webhook, failures are reported but do not fail the run */
+func (n notifier) send(event map[string]any) {
+	content, err := json.Marshal(event)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to notify: %v\n", err)
+		return
+	}
+	if n.command != "" {
+		cmd := exec.Command(n.ev.Interpreter, "-c", n.command)
+		cmd.Stdin = bytes.NewReader(content)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "notify-cmd failed: %v\n", err)
+		}
+	}
+	if n.webhook != "" {
+		resp, err := n.ev.HTTPPost(n.webhook, "application/json", bytes.NewReader(content))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "notify-webhook failed: %v\n", err)
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			fmt.Fprintf(os.Stderr, "notify-webhook failed: %s\n", resp.Status)
+		}
+	}
+}
+
+/* failed notifies that the builder of an output failed */
+func (n notifier) failed(err *types.BuildError) {
+	n.send(map[string]any{
+		"event":    "failed",
+		"file":     n.ev.RootFile,
+		"output":   err.Hash,
+		"position": err.Pos(),
+		"log":      err.LogPath,
+		"error":    err.Err.Error(),
+	})
+}
+
+/* finished notifies that the run ended, with `err` if it failed */
+func (n notifier) finished(err error, wall time.Duration) {
+	var (
+		built  []string
+		cached int
+	)
+	for _, info := range uniqueOutputs(n.ev) {
+		if info.Cached {
+			cached++
+		} else if !n.ev.DryRun {
+			built = append(built, path.Base(info.Path))
+		}
+	}
+	event := map[string]any{
+		"event":    "finished",
+		"file":     n.ev.RootFile,
+		"success":  err == nil,
+		"duration": wall.Seconds(),
+		"built":    built,
+		"cached":   cached,
+		"failed":   n.ev.Stats.Failed,
+	}
+	if err != nil {
+		event["error"] = err.Error()
+	}
+	n.send(event)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* content-addressed blob of an OCI image */
+type ociBlob struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	content []byte
+}
+
+func newBlob(mediaType string, content []byte) ociBlob {
+	sum := sha256.Sum256(content)
+	return ociBlob{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(content)), content: content}
+}
+
+func (blob ociBlob) path() string {
+	return path.Join("blobs", "sha256", strings.TrimPrefix(blob.Digest, "sha256:"))
+}
+
+/* storeLayer returns a tar-layer holding the store-entry at `storepath` and its parent directories */
+func storeLayer(storepath string) (ociBlob, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	parents := strings.Split(strings.Trim(path.Dir(storepath), "/"), "/")
+	for i := range parents {
+		hdr := &tar.Header{Name: path.Join(parents[:i+1]...) + "/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: time.Unix(1, 0)}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return ociBlob{}, err
+		}
+	}
+	if err := addTree(tw, storepath, strings.TrimPrefix(storepath, "/")); err != nil {
+		return ociBlob{}, err
+	}
+	if err := tw.Close(); err != nil {
+		return ociBlob{}, err
+	}
+	return newBlob("application/vnd.oci.image.layer.v1.tar", buf.Bytes()), nil
+}
+
+/* writeImage writes an OCI image-layout of `root` and its closure as tar to `w`,
+ * the archive also contains a manifest.json so older docker versions can load it */
+func writeImage(w io.Writer, cachedir string, index map[string]types.IndexEntry, root string, tag string, entrypoint []string) error {
+	var (
+		layers  []ociBlob
+		diffIDs []string
+	)
+	for _, name := range closure(index, root) {
+		layer, err := storeLayer(storePath(cachedir, index, name))
+		if err != nil {
+			return err
+		}
+		layers = append(layers, layer)
+		diffIDs = append(diffIDs, layer.Digest)
+	}
+
+	rootpath := storePath(cachedir, index, root)
+	config, err := json.Marshal(map[string]any{
+		"architecture": runtime.GOARCH,
+		"os":           runtime.GOOS,
+		"config": map[string]any{
+			"Entrypoint": entrypoint,
+			"Env":        []string{"PATH=" + path.Join(rootpath, "bin") + ":/bin:/usr/bin"},
+			"WorkingDir": "/",
+		},
+		"rootfs": map[string]any{
+			"type":     "layers",
+			"diff_ids": diffIDs,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	configBlob := newBlob("application/vnd.oci.image.config.v1+json", config)
+
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        configBlob,
+		"layers":        layers,
+	})
+	if err != nil {
+		return err
+	}
+	manifestBlob := newBlob("application/vnd.oci.image.manifest.v1+json", manifest)
+	manifestBlob.Annotations = map[string]string{
+		"io.containerd.image.name":          tag,
+		"org.opencontainers.image.ref.name": tag[strings.LastIndex(tag, ":")+1:],
+	}
+
+	layerPaths := make([]string, len(layers))
+	for i, layer := range layers {
+		layerPaths[i] = layer.path()
+	}
+	files := []struct {
+		name  string
+		value any
+	}{
+		{"oci-layout", map[string]string{"imageLayoutVersion": "1.0.0"}},
+		{"index.json", map[string]any{
+			"schemaVersion": 2,
+			"mediaType":     "application/vnd.oci.image.index.v1+json",
+			"manifests":     []ociBlob{manifestBlob},
+		}},
+		{"manifest.json", []map[string]any{{
+			"Config":   configBlob.path(),
+			"RepoTags": []string{tag},
+			"Layers":   layerPaths,
+		}}},
+	}
+
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		if err := writeJSON(tw, file.name, file.value); err != nil {
+			return err
+		}
+	}
+	written := make(map[string]bool)
+	for _, blob := range append([]ociBlob{configBlob, manifestBlob}, layers...) {
+		if written[blob.Digest] {
+			continue
+		}
+		written[blob.Digest] = true
+		hdr := &tar.Header{Name: blob.path(), Mode: 0644, Size: blob.Size, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(blob.content); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func ociMain(args []string) {
+	var (
+		ev         types.Evaluator
+		tag        string
+		outfile    string
+		entrypoint []string
+	)
+
+	flags := flag.NewFlagSet("oci", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon oci [options] <result|name|hash> -t name:tag > image.tar\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.StringVarP(&tag, "tag", "t", "", "name and tag of the image")
+	flags.StringVarP(&outfile, "output", "o", "", "write the image to `file` instead of stdout")
+	flags.StringArrayVar(&entrypoint, "entrypoint", nil, "entrypoint of the image, `mainProgram` of the result by default")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	index, err := types.ReadIndex(ev.CacheDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	root, err := storeEntry(ev.CacheDir, index, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if tag == "" {
+		tag = index[root].Name
+	}
+	if !strings.Contains(path.Base(tag), ":") {
+		tag += ":latest"
+	}
+	if len(entrypoint) == 0 && index[root].MainProgram != "" {
+		entrypoint = []string{path.Join(storePath(ev.CacheDir, index, root), index[root].MainProgram)}
+	}
+
+	var out io.Writer = os.Stdout
+	if outfile != "" {
+		file, err := os.Create(outfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := writeImage(out, ev.CacheDir, index, root, tag, entrypoint); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
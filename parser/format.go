@@ -0,0 +1,425 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* options of Format */
+type FormatOptions struct {
+	Indent string /* indentation of nested maps and arrays, two spaces if empty */
+	Dir    string /* paths inside of this directory are written relative to it, absolute if empty */
+	Width  int    /* arrays longer than this are split over multiple lines, 80 if 0 */
+}
+
+/* Format renders `expr` as canonical zon-source, comments other than `##` doc comments are not part of
+ * the expression and are lost */
+func Format(expr types.Expression, opts FormatOptions) (string, error) {
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+	if opts.Width == 0 {
+		opts.Width = 80
+	}
+	f := formatter{opts: opts}
+	if err := f.expr(expr); err != nil {
+		return "", err
+	}
+	f.WriteByte('\n')
+	return f.String(), nil
+}
+
+type formatter struct {
+	strings.Builder
+	opts  FormatOptions
+	depth int
+}
+
+func (f *formatter) newline() {
+	f.WriteByte('\n')
+	f.WriteString(strings.Repeat(f.opts.Indent, f.depth))
+}
+
+/* doc writes the lines of a doc comment, each followed by a newline */
+func (f *formatter) doc(doc string) {
+	for _, line := range strings.Split(strings.TrimSuffix(doc, "\n"), "\n") {
+		if line == "" && doc == "" {
+			return
+		}
+		f.WriteString("## " + line)
+		f.newline()
+	}
+}
+
+/* isIdent reports whether `name` can be written as variable or attribute without quotes */
+func isIdent(name string) bool {
+	if name == "" {
+		return false
+	}
+	if _, ok := keywords[name]; ok {
+		return false
+	}
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+/* operand writes `expr` enclosed in parentheses if it would take up what follows it */
+func (f *formatter) operand(expr types.Expression) error {
+	switch expr.(type) {
+	case types.OperationExpr, types.DefineExpr, types.InputsExpr, types.LambdaExpr, types.ConditionExpr,
+		types.IncludeExpr, types.OutputExpr:
+		f.WriteByte('(')
+		defer f.WriteByte(')')
+	}
+	return f.expr(expr)
+}
+
+func (f *formatter) expr(expr types.Expression) error {
+	switch expr := expr.(type) {
+	case types.NullExpr:
+		f.WriteString("null")
+	case types.BooleanExpr:
+		f.WriteString(strconv.FormatBool(expr.Value))
+	case types.NumberExpr:
+		f.WriteString(strconv.FormatFloat(expr.Value, 'g', -1, 64))
+	case types.StringExpr:
+		f.string(expr)
+	case types.PathExpr:
+		return f.path(expr.Name)
+	case types.GlobExpr:
+		return f.path(expr.Pattern)
+	case types.VarExpr:
+		f.WriteString(expr.Name)
+		if len(expr.Args) > 0 {
+			return f.list("(", expr.Args, ")")
+		}
+	case types.AttributeExpr:
+		if err := f.operand(expr.Base); err != nil {
+			return err
+		}
+		if expr.Optional {
+			f.WriteString(".?")
+		} else {
+			f.WriteByte('.')
+		}
+		if isIdent(expr.Name) {
+			f.WriteString(expr.Name)
+		} else {
+			f.string(types.StringConstant(expr.Name, ""))
+		}
+	case types.CallExpr:
+		if err := f.operand(expr.Base); err != nil {
+			return err
+		}
+		return f.list("(", expr.Args, ")")
+	case types.OperationExpr:
+		switch expr.Left.(type) {
+		case types.OperationExpr:
+			/* operators are left-associative */
+			if err := f.expr(expr.Left); err != nil {
+				return err
+			}
+		default:
+			if err := f.operand(expr.Left); err != nil {
+				return err
+			}
+		}
+		f.WriteString(" " + expr.Operator + " ")
+		return f.operand(expr.Right)
+	case types.ArrayExpr:
+		return f.array(expr)
+	case types.MapExpr:
+		return f.mapExpr(expr)
+	case types.IncludeExpr:
+		f.WriteString("include ")
+		return f.expr(expr.Name)
+	case types.OutputExpr:
+		f.WriteString("output ")
+		return f.expr(expr.Attrs)
+	case types.ConditionExpr:
+		f.WriteString("if ")
+		if err := f.expr(expr.Cond); err != nil {
+			return err
+		}
+		f.WriteString(" then ")
+		if err := f.expr(expr.Truly); err != nil {
+			return err
+		}
+		f.WriteString(" else ")
+		return f.expr(expr.Falsy)
+	case types.LambdaExpr:
+		return f.lambda(expr)
+	case types.DefineExpr:
+		return f.define(expr)
+	case types.InputsExpr:
+		f.WriteString("inputs ")
+		if err := f.expr(expr.Inputs); err != nil {
+			return err
+		}
+		f.WriteString(" in ")
+		return f.expr(expr.Expr)
+	default:
+		return fmt.Errorf("unable to format %T", expr)
+	}
+	return nil
+}
+
+/* string writes `expr` as "" string, or as '' string if it spans multiple lines */
+func (f *formatter) string(expr types.StringExpr) {
+	multiline := slices.ContainsFunc(expr.Content, func(s string) bool { return strings.Contains(s, "\n") })
+	if multiline {
+		f.WriteString("''")
+	} else {
+		f.WriteByte('"')
+	}
+	for i, content := range expr.Content {
+		runes := []rune(content)
+		for j, r := range runes {
+			switch {
+			case r == '\\':
+				f.WriteString(`\\`)
+			case multiline && r == '\'' && (j == len(runes)-1 || runes[j+1] == '\''):
+				/* '' ends the string */
+				f.WriteString(`\'`)
+			case multiline && (r == '\n' || r == '\t'):
+				f.WriteRune(r)
+			case !multiline && r == '"':
+				f.WriteString(`\"`)
+			case r == '\n':
+				f.WriteString(`\n`)
+			case r == '\r':
+				f.WriteString(`\r`)
+			case r == '\t':
+				f.WriteString(`\t`)
+			case r < 0x20 || r == 0x7f:
+				fmt.Fprintf(f, `\u{%x}`, r)
+			default:
+				f.WriteRune(r)
+			}
+		}
+		if i < len(expr.Interp) && expr.Interp[i] != nil {
+			f.WriteString(`\(`)
+			f.expr(expr.Interp[i])
+			f.WriteByte(')')
+		}
+	}
+	if multiline {
+		f.WriteString("''")
+	} else {
+		f.WriteByte('"')
+	}
+}
+
+/* path writes the absolute path `name`, relative to FormatOptions.Dir if it is set */
+func (f *formatter) path(name string) error {
+	if f.opts.Dir != "" {
+		if rel, err := filepath.Rel(f.opts.Dir, name); err == nil {
+			if !strings.HasPrefix(rel, "../") && rel != ".." {
+				rel = "./" + rel
+			}
+			name = rel
+		}
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune(",{}[]()'\"", r) }) {
+		return fmt.Errorf("unable to format path %s", name)
+	}
+	f.WriteString(name)
+	return nil
+}
+
+/* list writes `exprs` separated by commas on one line */
+func (f *formatter) list(open string, exprs []types.Expression, end string) error {
+	f.WriteString(open)
+	for i, elem := range exprs {
+		if i > 0 {
+			f.WriteString(", ")
+		}
+		if err := f.expr(elem); err != nil {
+			return err
+		}
+	}
+	f.WriteString(end)
+	return nil
+}
+
+func (f *formatter) array(expr types.ArrayExpr) error {
+	if len(expr.Exprs) == 0 {
+		f.WriteString("[]")
+		return nil
+	}
+	/* short arrays stay on one line */
+	inline := formatter{opts: f.opts}
+	if err := inline.list("[ ", expr.Exprs, " ]"); err == nil && !strings.Contains(inline.String(), "\n") && inline.Len() <= f.opts.Width {
+		f.WriteString(inline.String())
+		return nil
+	}
+	f.WriteByte('[')
+	f.depth++
+	for _, elem := range expr.Exprs {
+		f.newline()
+		if err := f.expr(elem); err != nil {
+			return err
+		}
+		f.WriteByte(',')
+	}
+	f.depth--
+	f.newline()
+	f.WriteByte(']')
+	return nil
+}
+
+/* inherits returns the names of the consecutive entries of `expr` from the `i`th key on which are written
+ * as `inherit`, entries with a doc comment are not */
+func inherits(expr types.MapExpr, i int) []string {
+	var names []string
+	for ; i < len(expr.Exprs)/2 && expr.Doc(i) == ""; i++ {
+		key, ok := types.ConstantKey(expr.Exprs[2*i])
+		if !ok || !inheritedVar(key, expr.Exprs[2*i+1]) {
+			break
+		}
+		names = append(names, key)
+	}
+	return names
+}
+
+/* inheritedVar reports whether `expr` refers to the variable `name` */
+func inheritedVar(name string, expr types.Expression) bool {
+	v, ok := expr.(types.VarExpr)
+	return ok && v.Name == name && len(v.Args) == 0
+}
+
+func (f *formatter) mapExpr(expr types.MapExpr) error {
+	if expr.Recursive {
+		f.WriteString("rec ")
+	}
+	if len(expr.Exprs) == 0 && len(expr.Extends) == 0 {
+		f.WriteString("{}")
+		return nil
+	}
+	/* short maps without doc comments stay on one line */
+	if !slices.ContainsFunc(expr.Docs, func(d string) bool { return d != "" }) {
+		inline := formatter{opts: f.opts}
+		if err := inline.entries(expr, false); err == nil && !strings.Contains(inline.String(), "\n") && inline.Len() <= f.opts.Width {
+			f.WriteString(inline.String())
+			return nil
+		}
+	}
+	return f.entries(expr, true)
+}
+
+/* entries writes the body of `expr` on one line or, if `multiline` is set, one entry per line */
+func (f *formatter) entries(expr types.MapExpr, multiline bool) error {
+	sep := func() {
+		if multiline {
+			f.newline()
+		} else {
+			f.WriteByte(' ')
+		}
+	}
+	/* a single line has no trailing comma */
+	comma := func(last bool) {
+		if multiline || !last {
+			f.WriteByte(',')
+		}
+	}
+	f.WriteByte('{')
+	f.depth++
+	for i, ext := range expr.Extends {
+		sep()
+		f.WriteString("with ")
+		if err := f.expr(ext); err != nil {
+			return err
+		}
+		comma(i == len(expr.Extends)-1 && len(expr.Exprs) == 0)
+	}
+	for i := 0; i < len(expr.Exprs)/2; i++ {
+		sep()
+		if names := inherits(expr, i); len(names) > 0 {
+			f.WriteString("inherit " + strings.Join(names, " ") + ";")
+			i += len(names) - 1
+			continue
+		}
+		f.doc(expr.Doc(i))
+		if err := f.expr(expr.Exprs[2*i]); err != nil {
+			return err
+		}
+		f.WriteString(": ")
+		if err := f.expr(expr.Exprs[2*i+1]); err != nil {
+			return err
+		}
+		comma(i == len(expr.Exprs)/2-1)
+	}
+	f.depth--
+	if multiline {
+		f.newline()
+	} else {
+		f.WriteByte(' ')
+	}
+	f.WriteByte('}')
+	return nil
+}
+
+func (f *formatter) lambda(expr types.LambdaExpr) error {
+	if !expr.Attrs {
+		f.WriteString("fn(" + strings.Join(expr.Args, ", ") + ") ")
+		return f.expr(expr.Expr)
+	}
+	f.WriteString("fn { ")
+	for i, name := range expr.Args {
+		if i > 0 {
+			f.WriteString(", ")
+		}
+		f.WriteString(name)
+		if def, ok := expr.Defaults[name]; ok {
+			f.WriteString(" = ")
+			if err := f.expr(def); err != nil {
+				return err
+			}
+		}
+	}
+	f.WriteString(" } ")
+	return f.expr(expr.Expr)
+}
+
+func (f *formatter) define(expr types.DefineExpr) error {
+	f.WriteString("let")
+	f.depth++
+	var inherit, names []string
+	for _, name := range slices.Sorted(maps.Keys(expr.Define)) {
+		if expr.Docs[name] == "" && inheritedVar(name, expr.Define[name]) {
+			inherit = append(inherit, name)
+		} else {
+			names = append(names, name)
+		}
+	}
+	if len(inherit) > 0 {
+		f.newline()
+		f.WriteString("inherit " + strings.Join(inherit, " ") + ";")
+	}
+	for i, name := range names {
+		f.newline()
+		f.doc(expr.Docs[name])
+		f.WriteString(name + " = ")
+		if err := f.expr(expr.Define[name]); err != nil {
+			return err
+		}
+		if i < len(names)-1 {
+			f.WriteByte(',')
+		}
+	}
+	f.depth--
+	f.newline()
+	f.WriteString("in ")
+	return f.expr(expr.Expr)
+}
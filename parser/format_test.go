@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* equalAST compares `a` and `b` ignoring their positions */
+func equalAST(a, b reflect.Value) bool {
+	if a.Kind() != b.Kind() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalAST(a.Elem(), b.Elem())
+	case reflect.Struct:
+		if a.Type() != b.Type() {
+			return false
+		}
+		if a.Type() == reflect.TypeOf(types.Position{}) {
+			return true
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if !equalAST(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalAST(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.MapKeys() {
+			if !b.MapIndex(key).IsValid() || !equalAST(a.MapIndex(key), b.MapIndex(key)) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Equal(b)
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"escapes", `"tab\there \"q\" back\\slash \u{1}"`, `"tab\there \"q\" back\\slash \u{1}"`},
+		{"unicode escape", `"\u00e9"`, `"é"`},
+		{"interpolation", `"\(name)-\(a + "-" + b)"`, `"\(name)-\(a + "-" + b)"`},
+		{"nested interpolation", `"a\("b\(c)")"`, `"a\("b\(c)")"`},
+		{"multiline", "''\n  line \\(x)\n  it's\n''", "''\n  line \\(x)\n  it's\n''"},
+		{"multiline quotes", "''\n'''quoted'''\n''", "''\n\\''quoted\\''\n''"},
+		{"multiline single line", "''a'''''", `"a''"`},
+		{"rec", `rec { "a": 1, "b": a }`, `rec { "a": 1, "b": a }`},
+		{"inherit", `let x = 1, y = 2 in { inherit x y; "z": 3 }`, "let\n  x = 1,\n  y = 2\nin { inherit x y; \"z\": 3 }"},
+		{"inherit in let", `let x = 1 in let inherit x; in x`, "let\n  x = 1\nin let\n  inherit x;\nin x"},
+		{"or", `a.b or c.d or 1`, `a.b or c.d or 1`},
+		{"optional", `a.?b.c or null`, `a.?b.c or null`},
+		{"quoted attribute", `{ "with space": 1, "if": 2 }."with space"`, `{ "with space": 1, "if": 2 }."with space"`},
+		{"keyword attribute", `m."if"`, `m."if"`},
+		{"attrset call", `f { "a": 1 }`, `f({ "a": 1 })`},
+		{"attrset lambda", `fn { a, b = 2 } a + b`, `fn { a, b = 2 } a + b`},
+		{"comments", "// comment\n{ /* inline */ \"a\": 1 }", `{ "a": 1 }`},
+		{"doc comments", "{\n  ## doc of a\n  ## second line\n  \"a\": 1,\n  \"b\": 2\n}", "{\n  ## doc of a\n  ## second line\n  \"a\": 1,\n  \"b\": 2,\n}"},
+		{"doc comment in let", "let\n  ## doc of x\n  x = 1\nin x", "let\n  ## doc of x\n  x = 1\nin x"},
+		{"left associative", `(a + b) + c`, `a + b + c`},
+		{"right operand", `a + (b + c)`, `a + (b + c)`},
+		{"mixed operators", `(a == b) or (c != d)`, `a == b or (c != d)`},
+		{"condition operand", `(if a then b else c) + 1`, `(if a then b else c) + 1`},
+		{"condition body", `if a then b else c + 1`, `if a then b else c + 1`},
+		{"lambda call", `(fn(a) a)(1)`, `(fn(a) a)(1)`},
+		{"let operand", `(let x = 1 in x) + 1`, "(let\n  x = 1\nin x) + 1"},
+		{"output", `output { "name": "x", "output": "true" }`, `output { "name": "x", "output": "true" }`},
+		{"path", `./dir/file`, `./dir/file`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := ParseString(test.src, "/src/test.zon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Format(expr, FormatOptions{Dir: "/src"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want+"\n" {
+				t.Errorf("got %q, want %q", got, test.want+"\n")
+			}
+			/* the formatted source parses to the same expression and formats to itself */
+			reparsed, err := ParseString(got, "/src/test.zon")
+			if err != nil {
+				t.Fatalf("unable to parse formatted source: %v", err)
+			}
+			if !equalAST(reflect.ValueOf(expr), reflect.ValueOf(reparsed)) {
+				t.Errorf("formatted source parses to %#v, want %#v", reparsed, expr)
+			}
+			if again, err := Format(reparsed, FormatOptions{Dir: "/src"}); err != nil || again != got {
+				t.Errorf("formatting again results in %q, error %v", again, err)
+			}
+		})
+	}
+}
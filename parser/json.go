@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* jsonParser converts JSON-documents to expressions, keeping order and positions */
+type jsonParser struct {
+	dec      *json.Decoder
+	content  []byte
+	filename string
+}
+
+func (p *jsonParser) base() types.Position {
+	off := int(p.dec.InputOffset())
+	for off < len(p.content) && bytes.ContainsRune([]byte(" \t\r\n,:"), rune(p.content[off])) {
+		off++
+	}
+	return types.Position{
+		Filename: p.filename,
+		Line:     bytes.Count(p.content[:off], []byte("\n")) + 1,
+		Offset:   off - (bytes.LastIndexByte(p.content[:off], '\n') + 1),
+	}
+}
+
+func (p *jsonParser) parseValue() (types.Expression, error) {
+	pos := p.base()
+	tok, err := p.dec.Token()
+	if err != nil {
+		return nil, &types.ParseError{Position: pos, Err: err}
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			obj := types.MapExpr{Position: pos}
+			for p.dec.More() {
+				key, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				value, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				obj.Exprs = append(obj.Exprs, key, value)
+			}
+			_, err := p.dec.Token()
+			return obj, err
+		case '[':
+			obj := types.ArrayExpr{Position: pos}
+			for p.dec.More() {
+				value, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				obj.Exprs = append(obj.Exprs, value)
+			}
+			_, err := p.dec.Token()
+			return obj, err
+		}
+	case string:
+		return types.StringExpr{Position: pos, Content: []string{tok}, Interp: []types.Expression{nil}}, nil
+	case json.Number:
+		val, err := strconv.ParseFloat(tok.String(), 64)
+		if err != nil {
+			return nil, &types.ParseError{Position: pos, Err: err}
+		}
+		return types.NumberExpr{Position: pos, Value: val}, nil
+	case bool:
+		return types.BooleanExpr{Position: pos, Value: tok}, nil
+	case nil:
+		return types.NullExpr{Position: pos}, nil
+	}
+	return nil, parseErrorf(pos, "unexpected token: %v", tok)
+}
+
+/* parseJSON parses a JSON-document as data */
+func parseJSON(content []byte, filename string) (types.Expression, error) {
+	p := jsonParser{
+		dec:      json.NewDecoder(bytes.NewReader(content)),
+		content:  content,
+		filename: filename,
+	}
+	p.dec.UseNumber()
+	val, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if p.dec.More() {
+		return nil, parseErrorf(p.base(), "expected end-of-file")
+	}
+	return val, nil
+}
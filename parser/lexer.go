@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type State int
@@ -22,16 +23,42 @@ const (
 	StateComment
 )
 
+func (m State) String() string {
+	switch m {
+	case StateRoot:
+		return "root"
+	case StateString:
+		return "string"
+	case StateStringEscape:
+		return "string-escape"
+	case StateMultilineString:
+		return "multiline-string"
+	case StateInterp:
+		return "interpolation"
+	case StateIdent:
+		return "identifier"
+	case StatePath:
+		return "path"
+	case StateComment:
+		return "comment"
+	}
+	return "<unknown>"
+}
+
+/* Scanner splits a source into tokens, it works on byte-offsets into the current line,
+ * Start and End are columns in runes */
 type Scanner struct {
 	scanner *bufio.Scanner
-	runes   []rune
-	current string
+	line    string /* line which is scanned, including the newline */
+	pos     int    /* byte-offset of the next character in line */
+	start   int    /* byte-offset of the current token in line */
 	stack   []State
 
 	Linenr int /* incremented by scan */
 	End    int /* incremented by consume */
 	Start  int
 	Token  Token
+	Doc    string /* `##` comments preceding the current token */
 }
 
 func NewScanner(r io.Reader) *Scanner {
@@ -41,35 +68,71 @@ func NewScanner(r io.Reader) *Scanner {
 	}
 }
 
-var lastSymbol tokenMatch
+/* first characters of the symbols, so most characters are not matched against every symbol */
+var symbolStart = func() string {
+	var chars strings.Builder
+	for _, v := range symbols {
+		chars.WriteByte(v.text[0])
+	}
+	return chars.String()
+}()
 
-func isSymbol(r string) bool {
+/* matchSymbol returns the symbol `str` starts with */
+func matchSymbol(str string) (tokenMatch, bool) {
 	for _, v := range symbols {
-		if strings.HasPrefix(r, v.text) {
-			lastSymbol = v
-			return true
+		if strings.HasPrefix(str, v.text) {
+			return v, true
 		}
 	}
-	return false
+	return tokenMatch{}, false
+}
+
+func isPathPrefix(str string) bool {
+	return strings.HasPrefix(str, "/") || strings.HasPrefix(str, "./") || strings.HasPrefix(str, "../")
+}
+
+func (s *Scanner) Text() string {
+	return s.line[s.start:s.pos]
+}
+
+/* rest returns the unscanned part of the line */
+func (s *Scanner) rest() string {
+	return s.line[s.pos:]
+}
+
+/* mark starts the current token at the next character */
+func (s *Scanner) mark() {
+	s.Start, s.start = s.End, s.pos
 }
 
-func isPathPrefix(rs []rune) bool {
-	str := string(rs)
-	for _, pre := range []string{"/", "./", "../"} {
-		if strings.HasPrefix(str, pre) {
-			return true
+/* firstRunes returns the first `n` runes of `str` */
+func firstRunes(str string, n int) string {
+	for i := range str {
+		if n == 0 {
+			return str[:i]
 		}
+		n--
 	}
-	return false
+	return str
 }
 
-func (s *Scanner) Text() string {
-	return s.current[s.Start:s.End]
+/* State returns the state the scanner is in after the current token */
+func (s *Scanner) State() State {
+	if len(s.stack) == 0 {
+		return StateRoot
+	}
+	return s.stack[len(s.stack)-1]
 }
 
+/* consume advances `n` bytes */
 func (s *Scanner) consume(n int) {
-	s.runes = s.runes[n:]
-	s.End += n
+	s.End += utf8.RuneCountInString(s.line[s.pos : s.pos+n])
+	s.pos += n
+}
+
+/* skipLine advances to the end of the line */
+func (s *Scanner) skipLine() {
+	s.pos = len(s.line)
 }
 
 func (s *Scanner) pop() {
@@ -81,23 +144,25 @@ func (s *Scanner) push(m State) {
 }
 
 func (s *Scanner) Next() error {
+	s.Doc = ""
 	for {
 		if len(s.stack) == 0 {
-			s.Start = s.End
+			s.mark()
 			return fmt.Errorf("stack-underflow")
 		}
 
 		var chr rune = -1
-		if len(s.runes) == 0 {
-			if s.scanner.Scan() {
-				s.current = s.scanner.Text() + "\n"
-				s.runes = []rune(s.current)
-				s.Linenr++
-				s.End = 0
-			}
+		if s.pos == len(s.line) && s.scanner.Scan() {
+			var line strings.Builder
+			line.Grow(len(s.scanner.Bytes()) + 1)
+			line.Write(s.scanner.Bytes())
+			line.WriteByte('\n')
+			s.line, s.pos, s.start = line.String(), 0, 0
+			s.Linenr++
+			s.End = 0
 		}
-		if len(s.runes) > 0 {
-			chr = s.runes[0]
+		if s.pos < len(s.line) {
+			chr, _ = utf8.DecodeRuneInString(s.rest())
 		}
 
 		var (
@@ -109,9 +174,9 @@ func (s *Scanner) Next() error {
 		case StateRoot, StateInterp:
 			cont, err = s.scanRoot(chr, mode)
 		case StateString:
-			cont, err = s.scanString(chr)
+			cont, err = s.scanString(chr, false)
 		case StateMultilineString:
-			cont, err = s.scanMultiString(chr)
+			cont, err = s.scanString(chr, true)
 		case StateStringEscape:
 			cont, err = s.scanStringEscape(chr)
 		case StateIdent:
@@ -125,20 +190,20 @@ func (s *Scanner) Next() error {
 			return err
 		}
 		if chr == -1 {
-			s.Start = s.End
+			s.mark()
 			return fmt.Errorf("illegal token: end-of-line")
 		}
 	}
 }
 
 func (s *Scanner) scanComment() (bool, error) {
-	if len(s.runes) == 0 {
+	if s.pos == len(s.line) {
 		s.pop()
 	}
-	cons := strings.Index(string(s.runes), "*/")
+	cons := strings.Index(s.rest(), "*/")
 	if cons == -1 {
 		/* no comment end yet */
-		s.runes = s.runes[:0]
+		s.skipLine()
 	} else {
 		s.consume(cons + 2)
 		s.pop()
@@ -148,7 +213,7 @@ func (s *Scanner) scanComment() (bool, error) {
 
 func (s *Scanner) scanPath(chr rune) (bool, error) {
 	if !unicode.IsSpace(chr) && !strings.ContainsRune(",{}[]()'\"", chr) {
-		s.consume(1)
+		s.consume(utf8.RuneLen(chr))
 	} else {
 		s.Token = TokenPath
 		s.pop()
@@ -158,10 +223,10 @@ func (s *Scanner) scanPath(chr rune) (bool, error) {
 }
 
 func (s *Scanner) scanIdent(chr rune) (bool, error) {
-	if unicode.IsLetter(chr) || unicode.IsDigit(chr) {
-		s.consume(1)
+	if unicode.IsLetter(chr) || unicode.IsDigit(chr) || chr == '_' {
+		s.consume(utf8.RuneLen(chr))
 	} else {
-		if tok, ok := keywords[s.current[s.Start:s.End]]; ok {
+		if tok, ok := keywords[s.Text()]; ok {
 			s.Token = tok
 		} else {
 			s.Token = TokenIdent
@@ -172,54 +237,40 @@ func (s *Scanner) scanIdent(chr rune) (bool, error) {
 	return true, nil
 }
 
-func (s *Scanner) scanString(chr rune) (bool, error) {
-	switch chr {
-	case '\\':
-		s.Start = s.End
-		s.consume(1)
-		s.push(StateStringEscape)
-	case '"':
-		s.Token = TokenStringEnd
-		s.Start = s.End
-		s.consume(1)
-		s.pop()
-		return false, nil
-	case '\n':
-		s.Start = s.End
-		return false, fmt.Errorf("illegal token: `\n`")
-	case -1:
-		s.Start = s.End
-		return false, fmt.Errorf("illegal token: end-of-line")
-	default:
-		s.Token = TokenStringChar
-		s.consume(1)
-		s.Start = s.End - 1
+/* scanString scans a character of a "" string or, if `multiline`, of a '' string,
+ * escapes and interpolations are the same in both */
+func (s *Scanner) scanString(chr rune, multiline bool) (bool, error) {
+	switch {
+	case multiline && strings.HasPrefix(s.rest(), "'''"):
+		/* ''' is a literal '' */
+		s.Token = TokenStringEscape
+		s.mark()
+		s.consume(3)
 		return false, nil
-	}
-
-	return true, nil
-}
-
-func (s *Scanner) scanMultiString(chr rune) (bool, error) {
-	if strings.HasPrefix(string(s.runes), "''") {
+	case multiline && strings.HasPrefix(s.rest(), "''"), !multiline && chr == '"':
 		s.Token = TokenStringEnd
-		s.Start = s.End
-		s.consume(2)
+		s.mark()
+		if multiline {
+			s.consume(2)
+		} else {
+			s.consume(1)
+		}
 		s.pop()
 		return false, nil
-	}
-	switch chr {
-	case '\\':
-		s.Start = s.End
+	case chr == '\\':
+		s.mark()
 		s.consume(1)
 		s.push(StateStringEscape)
-	case -1:
-		s.Start = s.End
+	case chr == '\n' && !multiline:
+		s.mark()
+		return false, fmt.Errorf("illegal token: `\n`")
+	case chr == -1:
+		s.mark()
 		return false, fmt.Errorf("illegal token: end-of-line")
 	default:
 		s.Token = TokenStringChar
-		s.Start = s.End
-		s.consume(1)
+		s.mark()
+		s.consume(utf8.RuneLen(chr))
 		return false, nil
 	}
 	return true, nil
@@ -241,21 +292,32 @@ func (s *Scanner) scanStringEscape(chr rune) (bool, error) {
 		s.push(StateInterp)
 		return false, nil
 	case -1:
-		s.Start = s.End
+		s.mark()
 		return false, fmt.Errorf("illegal token: end-of-line")
 	case 'u':
-		if len(s.runes) < 5 {
-			s.Start = s.End
-			return false, fmt.Errorf("illegal unicode-escape: `\\%c`", chr)
+		/* \uXXXX or \u{X} up to \u{XXXXXX} */
+		hex, length := s.rest()[1:], 5
+		if strings.HasPrefix(hex, "{") {
+			end := strings.IndexByte(hex, '}')
+			if end == -1 {
+				end = len(hex)
+			}
+			hex, length = hex[1:end], end+2
+			if len(hex) == 0 || utf8.RuneCountInString(hex) > 6 {
+				return false, fmt.Errorf("illegal unicode-escape: `\\u{%s}`", hex)
+			}
+		} else if utf8.RuneCountInString(hex) >= 4 {
+			hex = firstRunes(hex, 4)
+		} else {
+			return false, fmt.Errorf("illegal unicode-escape: expected 4 hex-digits after `\\u`")
 		}
-		hex := s.runes[1:4]
-		if strings.ContainsFunc(string(hex), func(r rune) bool {
+		if strings.ContainsFunc(hex, func(r rune) bool {
 			return !unicode.Is(unicode.Hex_Digit, r)
 		}) {
-			return false, fmt.Errorf("illegal unicode-escape: `\\%c`", chr)
+			return false, fmt.Errorf("illegal unicode-escape: `%s` are not hex-digits", strings.TrimSpace(hex))
 		}
 		s.Token = TokenStringEscape
-		s.consume(5)
+		s.consume(length)
 		s.pop()
 		return false, nil
 	default:
@@ -267,53 +329,62 @@ func (s *Scanner) scanRoot(chr rune, mode State) (bool, error) {
 	switch {
 	case chr == -1:
 		s.Token = TokenEOF
-		s.Start = s.End
+		s.mark()
 		return false, nil
 	case unicode.IsSpace(chr):
-		s.consume(1)
-	case strings.HasPrefix(string(s.runes), "//"):
+		s.consume(utf8.RuneLen(chr))
+	case strings.HasPrefix(s.rest(), "##"):
+		/* doc comment, attached to the next token */
+		s.Doc += strings.TrimSpace(s.rest()[2:]) + "\n"
+		s.skipLine()
+	case strings.HasPrefix(s.rest(), "//"):
 		/* consume rest of the line */
-		s.runes = s.runes[:0]
-	case strings.HasPrefix(string(s.runes), "/*"):
+		s.skipLine()
+	case strings.HasPrefix(s.rest(), "/*"):
 		s.consume(2)
 		s.push(StateComment)
-	case isPathPrefix(s.runes):
+	case isPathPrefix(s.rest()):
 		s.push(StatePath)
-		s.Start = s.End
+		s.mark()
 	case chr == '"':
 		s.Token = TokenString
-		s.Start = s.End
+		s.mark()
 		s.consume(1)
 		s.push(StateString)
 		return false, nil
-	case strings.HasPrefix(string(s.runes), "''"):
+	case strings.HasPrefix(s.rest(), "''"):
 		s.Token = TokenString
-		s.Start = s.End
+		s.mark()
 		s.consume(2)
 		s.push(StateMultilineString)
 		return false, nil
 	case mode == StateInterp && chr == ')':
 		s.Token = TokenInterpEnd
-		s.Start = s.End
+		s.mark()
 		s.consume(1)
 		s.pop()
 		return false, nil
-	case isSymbol(string(s.runes)):
-		s.Token = lastSymbol.token
-		s.Start = s.End
-		s.consume(len(lastSymbol.text))
+	case strings.ContainsRune(symbolStart, chr):
+		sym, ok := matchSymbol(s.rest())
+		if !ok {
+			s.mark()
+			return false, fmt.Errorf("illegal token: `%c`", chr)
+		}
+		s.Token = sym.token
+		s.mark()
+		s.consume(len(sym.text))
 		return false, nil
-	case unicode.IsLetter(chr):
+	case unicode.IsLetter(chr) || chr == '_':
 		s.push(StateIdent)
-		s.Start = s.End
+		s.mark()
 	default:
-		if m := numberPattern.FindStringIndex(string(s.runes)); m != nil {
+		if m := numberPattern.FindStringIndex(s.rest()); m != nil {
 			s.Token = TokenNumber
-			s.Start = s.End
+			s.mark()
 			s.consume(m[1])
 			return false, nil
 		}
-		s.Start = s.End
+		s.mark()
 		return false, fmt.Errorf("illegal token: `%c`", chr)
 	}
 	return true, nil
@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+/* scanned token with its text and columns */
+type scanned struct {
+	tok        Token
+	text       string
+	line       int
+	start, end int
+}
+
+func scanAll(src string) ([]scanned, error) {
+	s := NewScanner(strings.NewReader(src))
+	var toks []scanned
+	for {
+		if err := s.Next(); err != nil {
+			return toks, err
+		}
+		if s.Token == TokenEOF {
+			return toks, nil
+		}
+		toks = append(toks, scanned{s.Token, s.Text(), s.Linenr, s.Start, s.End})
+	}
+}
+
+func TestScanner(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []scanned
+	}{
+		{"symbols", `{ a.?b == c }`, []scanned{
+			{TokenLBrace, "{", 1, 0, 1},
+			{TokenIdent, "a", 1, 2, 3},
+			{TokenOptionalDot, ".?", 1, 3, 5},
+			{TokenIdent, "b", 1, 5, 6},
+			{TokenEquals, "==", 1, 7, 9},
+			{TokenIdent, "c", 1, 10, 11},
+			{TokenRBrace, "}", 1, 12, 13},
+		}},
+		{"keywords", "let x = 1 in x", []scanned{
+			{TokenLet, "let", 1, 0, 3},
+			{TokenIdent, "x", 1, 4, 5},
+			{TokenAssign, "=", 1, 6, 7},
+			{TokenNumber, "1", 1, 8, 9},
+			{TokenIn, "in", 1, 10, 12},
+			{TokenIdent, "x", 1, 13, 14},
+		}},
+		{"columns count runes", `"é" ñ`, []scanned{
+			{TokenString, `"`, 1, 0, 1},
+			{TokenStringChar, "é", 1, 1, 2},
+			{TokenStringEnd, `"`, 1, 2, 3},
+			{TokenIdent, "ñ", 1, 4, 5},
+		}},
+		{"non-breaking space", "a\u00a0b", []scanned{
+			{TokenIdent, "a", 1, 0, 1},
+			{TokenIdent, "b", 1, 2, 3},
+		}},
+		{"paths and comments", "./a/b // rest\n/* x */ ../c", []scanned{
+			{TokenPath, "./a/b", 1, 0, 5},
+			{TokenPath, "../c", 2, 8, 12},
+		}},
+		{"multiline string", "''a'''b''", []scanned{
+			{TokenString, "''", 1, 0, 2},
+			{TokenStringChar, "a", 1, 2, 3},
+			{TokenStringEscape, "'''", 1, 3, 6},
+			{TokenStringChar, "b", 1, 6, 7},
+			{TokenStringEnd, "''", 1, 7, 9},
+		}},
+		{"escapes", `"\n\u00e9\u{1F600}\(x)"`, []scanned{
+			{TokenString, `"`, 1, 0, 1},
+			{TokenStringEscape, `\n`, 1, 1, 3},
+			{TokenStringEscape, `\u00e9`, 1, 3, 9},
+			{TokenStringEscape, `\u{1F600}`, 1, 9, 18},
+			{TokenInterp, `\(`, 1, 18, 20},
+			{TokenIdent, "x", 1, 20, 21},
+			{TokenInterpEnd, ")", 1, 21, 22},
+			{TokenStringEnd, `"`, 1, 22, 23},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := scanAll(test.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d tokens %v, want %d", len(got), got, len(test.want))
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("token %d: got %+v, want %+v", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScannerErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`"\q"`, "illegal escape"},
+		{`"\u12"`, "are not hex-digits"},
+		{`"\u{}"`, "illegal unicode-escape"},
+		{`"\u{1234567}"`, "illegal unicode-escape"},
+		{"\"ab\n", "illegal token"},
+		{"!", "illegal token"},
+	}
+	for _, test := range tests {
+		_, err := scanAll(test.src)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: got error %v, want %q", test.src, err, test.want)
+		}
+	}
+}
+
+/* benchSource returns a source of `n` map entries with strings, paths, numbers and comments */
+func benchSource(n int) string {
+	var src strings.Builder
+	src.WriteString("{\n")
+	for range n {
+		src.WriteString("  ## documented entry with ünïcödé\n")
+		src.WriteString(`  "key": { "name": "pkg é \(v)", "path": ./src/file.txt, /* inline */ "n": 12.5e3, "list": [ true, null ] }, // trailing` + "\n")
+	}
+	src.WriteString("}\n")
+	return src.String()
+}
+
+func BenchmarkScanner(b *testing.B) {
+	src := benchSource(1000)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for range b.N {
+		s := NewScanner(strings.NewReader(src))
+		for s.Token != TokenEOF || s.Linenr == 0 {
+			if err := s.Next(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	src := benchSource(1000)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := ParseString(src, "bench.zon"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
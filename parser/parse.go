@@ -2,12 +2,15 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf16"
 
 	"github.com/friedelschoen/zon/types"
 )
@@ -26,6 +29,19 @@ func (p *Parser) base() types.Position {
 	}
 }
 
+/* parseErrorf returns a ParseError at `pos`, `format` is formatted as in fmt.Errorf */
+func parseErrorf(pos types.Position, format string, args ...any) error {
+	return &types.ParseError{Position: pos, Err: fmt.Errorf(format, args...)}
+}
+
+/* next scans the next token, errors of the scanner are located at the current position */
+func (p *Parser) next() error {
+	if err := p.s.Next(); err != nil {
+		return &types.ParseError{Position: p.base(), End: p.s.End, Err: err}
+	}
+	return nil
+}
+
 func (p *Parser) expect(toks ...Token) error {
 	if !slices.Contains(toks, p.s.Token) {
 		var expected strings.Builder
@@ -35,9 +51,13 @@ func (p *Parser) expect(toks ...Token) error {
 			}
 			expected.WriteString(t.String())
 		}
-		return fmt.Errorf("%s:%d:%d-%d: expected %s, got '%s' (type %v)", path.Base(p.filename), p.s.Linenr, p.s.Start+1, p.s.End+1, expected.String(), p.s.Text(), p.s.Token)
+		return &types.ParseError{
+			Position: p.base(),
+			End:      p.s.End,
+			Err:      fmt.Errorf("expected %s, got '%s' (type %v)", expected.String(), p.s.Text(), p.s.Token),
+		}
 	}
-	if err := p.s.Next(); err != nil {
+	if err := p.next(); err != nil {
 		return err
 	}
 	return nil
@@ -49,11 +69,15 @@ func (p *Parser) parseString() (types.Expression, error) {
 	}
 
 	var builder strings.Builder
+	var high rune /* first half of a surrogate pair */
 	for {
-		if err := p.s.Next(); err != nil {
+		if err := p.next(); err != nil {
 			return nil, err
 		}
 
+		if high != 0 && (p.s.Token != TokenStringEscape || p.s.Text()[1] != 'u') {
+			return nil, parseErrorf(p.base(), "unpaired surrogate \\u%X", high)
+		}
 		switch p.s.Token {
 		case TokenStringChar:
 			builder.WriteString(p.s.Text())
@@ -63,6 +87,14 @@ func (p *Parser) parseString() (types.Expression, error) {
 			switch text[1] {
 			case '"':
 				builder.WriteByte('"')
+			case '\'':
+				if text == "'''" {
+					builder.WriteString("''")
+				} else {
+					builder.WriteByte('\'')
+				}
+			case '\n':
+				/* escaped line-break continues the line */
 			case '\\':
 				builder.WriteByte('\\')
 			case 'b':
@@ -76,18 +108,28 @@ func (p *Parser) parseString() (types.Expression, error) {
 			case 't':
 				builder.WriteByte('\t')
 			case 'u':
-				code, err := strconv.ParseInt(text[2:6], 16, 16)
+				code, err := unicodeEscape(text)
 				if err != nil {
-					return nil, err
+					return nil, parseErrorf(p.base(), "%w", err)
+				}
+				switch {
+				case code >= 0xD800 && code < 0xDC00 && high == 0:
+					high = code
+				case code >= 0xDC00 && code < 0xE000 && high != 0:
+					builder.WriteRune(utf16.DecodeRune(high, code))
+					high = 0
+				case utf16.IsSurrogate(code) || high != 0:
+					return nil, parseErrorf(p.base(), "unpaired surrogate %s", text)
+				default:
+					builder.WriteRune(code)
 				}
-				builder.WriteRune(rune(code))
 			}
 		case TokenStringEnd:
 			goto exit
 		case TokenInterp:
 			obj.Content = append(obj.Content, builder.String())
 			builder.Reset()
-			if err := p.s.Next(); err != nil {
+			if err := p.next(); err != nil {
 				return nil, err
 			}
 			intp, err := p.parseValue()
@@ -101,7 +143,7 @@ func (p *Parser) parseString() (types.Expression, error) {
 		}
 	}
 exit:
-	if err := p.s.Next(); err != nil {
+	if err := p.next(); err != nil {
 		return nil, err
 	}
 
@@ -110,10 +152,37 @@ exit:
 	return obj, nil
 }
 
+/* unicodeEscape decodes the code point of `\\uXXXX` or `\\u{X...}` */
+func unicodeEscape(text string) (rune, error) {
+	digits := strings.TrimSuffix(strings.TrimPrefix(text[2:], "{"), "}")
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	if code > unicode.MaxRune {
+		return 0, fmt.Errorf("code point out of range: %s", text)
+	}
+	return rune(code), nil
+}
+
 func (p *Parser) parseBase() (types.Expression, error) {
 	switch p.s.Token {
 	case TokenLBrace:
 		return p.parseMap()
+	case TokenRec:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.s.Token != TokenLBrace {
+			return nil, p.expect(TokenLBrace)
+		}
+		obj, err := p.parseMap()
+		if err != nil {
+			return nil, err
+		}
+		rec := obj.(types.MapExpr)
+		rec.Recursive = true
+		return rec, nil
 	case TokenLBracket:
 		return p.parseArray()
 	case TokenString:
@@ -136,7 +205,7 @@ func (p *Parser) parseBase() (types.Expression, error) {
 			Position: p.base(),
 			Value:    val,
 		}
-		if err := p.s.Next(); err != nil {
+		if err := p.next(); err != nil {
 			return nil, err
 		}
 		return obj, nil
@@ -148,7 +217,18 @@ func (p *Parser) parseBase() (types.Expression, error) {
 		if obj.Name[0] != '/' {
 			obj.Name = path.Clean(p.cwd + "/" + obj.Name)
 		}
-		if err := p.s.Next(); err != nil {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if strings.ContainsAny(obj.Name, "*?") {
+			return types.GlobExpr{Position: obj.Position, Pattern: obj.Name}, nil
+		}
+		return obj, nil
+	case TokenNull:
+		obj := types.NullExpr{
+			Position: p.base(),
+		}
+		if err := p.next(); err != nil {
 			return nil, err
 		}
 		return obj, nil
@@ -157,40 +237,91 @@ func (p *Parser) parseBase() (types.Expression, error) {
 			Position: p.base(),
 			Value:    p.s.Token == TokenTrue,
 		}
-		if err := p.s.Next(); err != nil {
+		if err := p.next(); err != nil {
 			return nil, err
 		}
 		return obj, nil
 	case TokenLet:
 		return p.parseDefinition()
+	case TokenInputs:
+		return p.parseInputs()
 	}
-	return nil, fmt.Errorf("%s: invalid token: %v", p.base(), p.s.Token)
+	return nil, parseErrorf(p.base(), "invalid token: %v", p.s.Token)
 }
 
 func (p *Parser) parseValue() (types.Expression, error) {
+	base, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for slices.Contains(operators, p.s.Token) {
+		pos := p.base()
+		op := p.s.Text()
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		other, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		base = types.OperationExpr{
+			Position: pos,
+			Operator: op,
+			Left:     base,
+			Right:    other,
+		}
+	}
+	return base, nil
+}
+
+/* parseOperand parses a value with its attribute-selections and calls, operators are left to parseValue */
+func (p *Parser) parseOperand() (types.Expression, error) {
 	base, err := p.parseBase()
 	if err != nil {
 		return nil, err
 	}
 
 	for {
-		if p.s.Token == TokenDot {
-			if err := p.s.Next(); err != nil {
+		if p.s.Token == TokenDot || p.s.Token == TokenOptionalDot {
+			optional := p.s.Token == TokenOptionalDot
+			if err := p.next(); err != nil {
 				return nil, err
 			}
+			if p.s.Token == TokenString {
+				/* quoted attribute like m."foo-bar" */
+				pos := p.base()
+				key, err := p.parseString()
+				if err != nil {
+					return nil, err
+				}
+				name, ok := types.ConstantKey(key)
+				if !ok {
+					return nil, parseErrorf(pos, "quoted attribute may not contain interpolations")
+				}
+				base = types.AttributeExpr{
+					Position: pos,
+					Base:     base,
+					Name:     name,
+					Optional: optional,
+				}
+				continue
+			}
 			if p.s.Token != TokenIdent {
-				return nil, p.expect(TokenIdent)
+				return nil, p.expect(TokenIdent, TokenString)
 			}
 			base = types.AttributeExpr{
 				Position: p.base(),
 				Base:     base,
 				Name:     p.s.Text(),
+				Optional: optional,
 			}
-			if err := p.s.Next(); err != nil {
+			if err := p.next(); err != nil {
 				return nil, err
 			}
 		} else if p.s.Token == TokenLParen {
-			if err := p.s.Next(); err != nil {
+			if err := p.next(); err != nil {
 				return nil, err
 			}
 			var args []types.Expression
@@ -212,22 +343,17 @@ func (p *Parser) parseValue() (types.Expression, error) {
 			if err := p.expect(TokenRParen); err != nil {
 				return nil, err
 			}
-		} else if slices.Contains(operators, p.s.Token) {
+		} else if p.s.Token == TokenLBrace {
+			/* `f { ... }` calls f with a map */
 			pos := p.base()
-			op := p.s.Text()
-			if err := p.s.Next(); err != nil {
-				return nil, err
-			}
-
-			other, err := p.parseBase()
+			arg, err := p.parseMap()
 			if err != nil {
 				return nil, err
 			}
-			base = types.OperationExpr{
+			base = types.CallExpr{
 				Position: pos,
-				Operator: op,
-				Left:     base,
-				Right:    other,
+				Base:     base,
+				Args:     []types.Expression{arg},
 			}
 		} else {
 			break
@@ -247,7 +373,7 @@ func (p *Parser) parseMap() (types.Expression, error) {
 
 	for p.s.Token != TokenRBrace {
 		if p.s.Token == TokenWith {
-			if err := p.s.Next(); err != nil {
+			if err := p.next(); err != nil {
 				return nil, err
 			}
 			val, err := p.parseValue()
@@ -255,7 +381,19 @@ func (p *Parser) parseMap() (types.Expression, error) {
 				return nil, err
 			}
 			obj.Extends = append(obj.Extends, val)
+		} else if p.s.Token == TokenInherit {
+			vars, err := p.parseInherit()
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range vars {
+				key := types.StringExpr{Position: v.Position, Content: []string{v.Name}, Interp: []types.Expression{nil}}
+				obj.Docs = append(obj.Docs, "")
+				obj.Exprs = append(obj.Exprs, key, v)
+			}
+			continue
 		} else {
+			obj.Docs = append(obj.Docs, p.s.Doc)
 			key, err := p.parseValue()
 			if err != nil {
 				return nil, err
@@ -286,6 +424,7 @@ func (p *Parser) parseDefinition() (types.Expression, error) {
 	obj := types.DefineExpr{
 		Position: p.base(),
 		Define:   make(map[string]types.Expression),
+		Docs:     make(map[string]string),
 	}
 
 	err := p.expect(TokenLet)
@@ -294,7 +433,20 @@ func (p *Parser) parseDefinition() (types.Expression, error) {
 	}
 
 	for p.s.Token != TokenIn {
+		if p.s.Token == TokenInherit {
+			vars, err := p.parseInherit()
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range vars {
+				obj.Define[v.Name] = v
+			}
+			continue
+		}
 		keyStr := p.s.Text()
+		if p.s.Doc != "" {
+			obj.Docs[keyStr] = p.s.Doc
+		}
 		if err := p.expect(TokenIdent); err != nil {
 			return nil, err
 		}
@@ -325,6 +477,57 @@ func (p *Parser) parseDefinition() (types.Expression, error) {
 	return obj, nil
 }
 
+/* parseInherit parses `inherit a b;` including an optional trailing comma */
+func (p *Parser) parseInherit() ([]types.VarExpr, error) {
+	if err := p.expect(TokenInherit); err != nil {
+		return nil, err
+	}
+	var vars []types.VarExpr
+	for p.s.Token != TokenSemicolon {
+		v, err := p.parseVar()
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, v.(types.VarExpr))
+	}
+	if err := p.expect(TokenSemicolon); err != nil {
+		return nil, err
+	}
+	if p.s.Token == TokenComma {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return vars, nil
+}
+
+func (p *Parser) parseInputs() (types.Expression, error) {
+	obj := types.InputsExpr{
+		Position: p.base(),
+	}
+
+	if err := p.expect(TokenInputs); err != nil {
+		return nil, err
+	}
+
+	var err error
+	obj.Inputs, err = p.parseMap()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(TokenIn); err != nil {
+		return nil, err
+	}
+
+	obj.Expr, err = p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
 func (p *Parser) parseArray() (types.Expression, error) {
 	obj := types.ArrayExpr{
 		Position: p.base(),
@@ -369,7 +572,7 @@ func (p *Parser) parseInclude() (types.Expression, error) {
 		Position: p.base(),
 		Name:     nil,
 	}
-	if err := p.s.Next(); err != nil {
+	if err := p.next(); err != nil {
 		return nil, err
 	}
 	var err error
@@ -382,7 +585,7 @@ func (p *Parser) parseOutput() (types.Expression, error) {
 		Position: p.base(),
 		Attrs:    nil,
 	}
-	if err := p.s.Next(); err != nil {
+	if err := p.next(); err != nil {
 		return nil, err
 	}
 	var err error
@@ -397,6 +600,9 @@ func (p *Parser) parseLambda() (types.Expression, error) {
 	if err := p.expect(TokenFunction); err != nil {
 		return nil, err
 	}
+	if p.s.Token == TokenLBrace {
+		return p.parseAttrsLambda(obj)
+	}
 	if err := p.expect(TokenLParen); err != nil {
 		return nil, err
 	}
@@ -425,8 +631,46 @@ func (p *Parser) parseLambda() (types.Expression, error) {
 	return obj, nil
 }
 
+/* parseAttrsLambda parses the rest of `fn { a, b = default } body`, a function taking a map */
+func (p *Parser) parseAttrsLambda(obj types.LambdaExpr) (types.Expression, error) {
+	obj.Attrs = true
+	obj.Defaults = make(map[string]types.Expression)
+	if err := p.expect(TokenLBrace); err != nil {
+		return nil, err
+	}
+	for p.s.Token != TokenRBrace {
+		arg := p.s.Text()
+		if err := p.expect(TokenIdent); err != nil {
+			return nil, err
+		}
+		obj.Args = append(obj.Args, arg)
+		if p.s.Token == TokenAssign {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			def, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			obj.Defaults[arg] = def
+		}
+		if err := p.expect(TokenComma); err != nil {
+			break
+		}
+	}
+	if err := p.expect(TokenRBrace); err != nil {
+		return nil, err
+	}
+	body, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	obj.Expr = body
+	return obj, nil
+}
+
 func (p *Parser) parseEnclosed() (types.Expression, error) {
-	if err := p.s.Next(); err != nil {
+	if err := p.next(); err != nil {
 		return nil, err
 	}
 	obj, err := p.parseValue()
@@ -469,20 +713,11 @@ func (p *Parser) parseCondition() (types.Expression, error) {
 	return obj, nil
 }
 
-func ParseFile(filename types.PathExpr) (types.Expression, error) {
-	file, err := os.Open(filename.Name)
-	if err != nil {
-		return nil, fmt.Errorf("%s: failed to open file %s: %w", filename.Pos(), filename.Name, err)
-	}
-	defer file.Close()
-	abs, _ := filepath.Abs(filename.Name)
-
-	scanner := NewScanner(file)
-	err = scanner.Next()
-	if err != nil {
+func parse(r io.Reader, filename string, cwd string) (types.Expression, error) {
+	parser := Parser{s: NewScanner(r), cwd: cwd, filename: filename}
+	if err := parser.next(); err != nil {
 		return nil, err
 	}
-	parser := Parser{s: scanner, cwd: path.Dir(abs), filename: filename.Name}
 	val, err := parser.parseValue()
 	if err != nil {
 		return nil, err
@@ -492,3 +727,43 @@ func ParseFile(filename types.PathExpr) (types.Expression, error) {
 	}
 	return val, nil
 }
+
+/* Parse parses the source read from `r`, `filename` is used in positions and its extension selects JSON, YAML or TOML,
+ * relative paths are resolved from its directory */
+func Parse(r io.Reader, filename string) (types.Expression, error) {
+	var parseData func(content []byte, filename string) (types.Expression, error)
+	switch path.Ext(filename) {
+	case ".json":
+		parseData = parseJSON
+	case ".yaml", ".yml":
+		parseData = parseYAML
+	case ".toml":
+		parseData = parseTOML
+	}
+	if parseData != nil {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return parseData(content, filename)
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parse(r, filename, path.Dir(abs))
+}
+
+func ParseFile(filename types.PathExpr) (types.Expression, error) {
+	file, err := os.Open(filename.Name)
+	if err != nil {
+		return nil, parseErrorf(filename.Position, "failed to open file %s: %w", filename.Name, err)
+	}
+	defer file.Close()
+	return Parse(file, filename.Name)
+}
+
+/* ParseString parses `src` like Parse, names like <commandline> resolve relative paths from the current directory */
+func ParseString(src string, filename string) (types.Expression, error) {
+	return Parse(strings.NewReader(src), filename)
+}
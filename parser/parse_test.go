@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+func TestParseStringEscapes(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+		err  string
+	}{
+		{name: "simple", src: `"a\tb\n\"\\"`, want: "a\tb\n\"\\"},
+		{name: "four digits", src: `"\u00e9\u20AC"`, want: "é€"},
+		{name: "braces", src: `"\u{1F600}\u{41}"`, want: "😀A"},
+		{name: "surrogate pair", src: `"\uD83D\uDE00"`, want: "😀"},
+		{name: "surrogate pair in braces", src: `"\u{D83D}\u{DE00}"`, want: "😀"},
+		{name: "multiline", src: "''it'''s\\u{41}''", want: "it''sA"},
+		{name: "lone high surrogate", src: `"\uD83Dx"`, err: "unpaired surrogate"},
+		{name: "high surrogate at end", src: `"\uD83D"`, err: "unpaired surrogate"},
+		{name: "lone low surrogate", src: `"\uDE00"`, err: "unpaired surrogate"},
+		{name: "two high surrogates", src: `"\uD83D\uD83D"`, err: "unpaired surrogate"},
+		{name: "high surrogate before escape", src: `"\uD83D\n"`, err: "unpaired surrogate"},
+		{name: "out of range", src: `"\u{110000}"`, err: "\\u{110000}"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := ParseString(test.src, "test.zon")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			str, ok := expr.(types.StringExpr)
+			if !ok || len(str.Content) != 1 || str.Content[0] != test.want {
+				t.Errorf("got %#v, want %q", expr, test.want)
+			}
+		})
+	}
+}
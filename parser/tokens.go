@@ -19,16 +19,24 @@ const (
 	TokenIf                        /* if */
 	TokenIn                        /* in */
 	TokenInclude                   /* include */
+	TokenInherit                   /* inherit */
+	TokenInputs                    /* inputs */
 	TokenInterp                    /* \( */
 	TokenInterpEnd                 /* ) */
 	TokenLBrace                    /* { */
 	TokenLBracket                  /* [ */
 	TokenLParen                    /* ( */
 	TokenLet                       /* let */
+	TokenNull                      /* null */
 	TokenNumber                    /* 10 */
+	TokenOptionalDot               /* .? */
+	TokenOr                        /* or */
 	TokenOutput                    /* output */
 	TokenPath                      /* ../hello, ./foo */
+	TokenPlus                      /* + */
 	TokenRBrace                    /* } */
+	TokenRec                       /* rec */
+	TokenSemicolon                 /* ; */
 	TokenRBracket                  /* ] */
 	TokenRParen                    /* ) */
 	TokenString                    /* "hello */
@@ -58,14 +66,20 @@ var symbols = []tokenMatch{
 	{":", TokenColon},
 	{",", TokenComma},
 	{"=", TokenAssign},
+	{".?", TokenOptionalDot},
 	{".", TokenDot},
+	{"+", TokenPlus},
+	{";", TokenSemicolon},
 }
 
 var keywords = map[string]Token{
 	"true":    TokenTrue,
 	"false":   TokenFalse,
+	"null":    TokenNull,
 	"let":     TokenLet,
 	"include": TokenInclude,
+	"inherit": TokenInherit,
+	"inputs":  TokenInputs,
 	"in":      TokenIn,
 	"with":    TokenWith,
 	"output":  TokenOutput,
@@ -73,10 +87,12 @@ var keywords = map[string]Token{
 	"if":      TokenIf,
 	"then":    TokenThen,
 	"else":    TokenElse,
+	"rec":     TokenRec,
+	"or":      TokenOr,
 }
 
 var operators = []Token{
-	TokenEquals, TokenUnequals,
+	TokenEquals, TokenUnequals, TokenPlus, TokenOr,
 }
 
 func (t Token) String() string {
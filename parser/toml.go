@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"slices"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/friedelschoen/zon/types"
+)
+
+/* tomlConverter converts decoded TOML-values to expressions, ordering keys as in the document */
+type tomlConverter struct {
+	keys []toml.Key
+	pos  types.Position
+}
+
+/* order returns the keys of the table at `prefix` in order of appearance */
+func (c *tomlConverter) order(prefix toml.Key) []string {
+	var keys []string
+	for _, key := range c.keys {
+		if len(key) == len(prefix)+1 && slices.Equal(key[:len(prefix)], prefix) && !slices.Contains(keys, key[len(prefix)]) {
+			keys = append(keys, key[len(prefix)])
+		}
+	}
+	return keys
+}
+
+func (c *tomlConverter) convert(value any, prefix toml.Key) (types.Expression, error) {
+	switch value := value.(type) {
+	case map[string]any:
+		obj := types.MapExpr{Position: c.pos}
+		keys := c.order(prefix)
+		/* keys not reported by metadata, such as keys of inline tables in arrays */
+		for key := range value {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			elem, ok := value[key]
+			if !ok {
+				continue
+			}
+			expr, err := c.convert(elem, append(slices.Clone(prefix), key))
+			if err != nil {
+				return nil, err
+			}
+			obj.Exprs = append(obj.Exprs, types.StringExpr{Position: c.pos, Content: []string{key}, Interp: []types.Expression{nil}}, expr)
+		}
+		return obj, nil
+	case []map[string]any:
+		obj := types.ArrayExpr{Position: c.pos}
+		for _, elem := range value {
+			expr, err := c.convert(elem, prefix)
+			if err != nil {
+				return nil, err
+			}
+			obj.Exprs = append(obj.Exprs, expr)
+		}
+		return obj, nil
+	case []any:
+		obj := types.ArrayExpr{Position: c.pos}
+		for _, elem := range value {
+			expr, err := c.convert(elem, prefix)
+			if err != nil {
+				return nil, err
+			}
+			obj.Exprs = append(obj.Exprs, expr)
+		}
+		return obj, nil
+	case string:
+		return types.StringExpr{Position: c.pos, Content: []string{value}, Interp: []types.Expression{nil}}, nil
+	case int64:
+		return types.NumberExpr{Position: c.pos, Value: float64(value)}, nil
+	case float64:
+		return types.NumberExpr{Position: c.pos, Value: value}, nil
+	case bool:
+		return types.BooleanExpr{Position: c.pos, Value: value}, nil
+	case time.Time:
+		return types.StringExpr{Position: c.pos, Content: []string{value.Format(time.RFC3339)}, Interp: []types.Expression{nil}}, nil
+	}
+	return nil, parseErrorf(c.pos, "unable to convert %T", value)
+}
+
+/* parseTOML parses a TOML-document as data */
+func parseTOML(content []byte, filename string) (types.Expression, error) {
+	var value map[string]any
+	md, err := toml.Decode(string(content), &value)
+	if err != nil {
+		return nil, &types.ParseError{Position: types.Position{Filename: filename}, Err: err}
+	}
+	c := tomlConverter{
+		keys: md.Keys(),
+		pos:  types.Position{Filename: filename, Line: 1},
+	}
+	return c.convert(value, nil)
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"strconv"
+
+	"github.com/friedelschoen/zon/types"
+	"gopkg.in/yaml.v3"
+)
+
+/* yamlExpr converts a YAML-node to expressions, keeping order and positions */
+func yamlExpr(node *yaml.Node, filename string) (types.Expression, error) {
+	pos := types.Position{
+		Filename: filename,
+		Line:     node.Line,
+		Offset:   node.Column - 1,
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return types.NullExpr{Position: pos}, nil
+		}
+		return yamlExpr(node.Content[0], filename)
+	case yaml.AliasNode:
+		return yamlExpr(node.Alias, filename)
+	case yaml.MappingNode:
+		obj := types.MapExpr{Position: pos}
+		for _, elem := range node.Content {
+			expr, err := yamlExpr(elem, filename)
+			if err != nil {
+				return nil, err
+			}
+			obj.Exprs = append(obj.Exprs, expr)
+		}
+		return obj, nil
+	case yaml.SequenceNode:
+		obj := types.ArrayExpr{Position: pos}
+		for _, elem := range node.Content {
+			expr, err := yamlExpr(elem, filename)
+			if err != nil {
+				return nil, err
+			}
+			obj.Exprs = append(obj.Exprs, expr)
+		}
+		return obj, nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return types.NullExpr{Position: pos}, nil
+		case "!!bool":
+			var val bool
+			if err := node.Decode(&val); err != nil {
+				return nil, &types.ParseError{Position: pos, Err: err}
+			}
+			return types.BooleanExpr{Position: pos, Value: val}, nil
+		case "!!int", "!!float":
+			var val float64
+			if err := node.Decode(&val); err != nil {
+				return nil, &types.ParseError{Position: pos, Err: err}
+			}
+			return types.NumberExpr{Position: pos, Value: val}, nil
+		}
+		return types.StringExpr{Position: pos, Content: []string{node.Value}, Interp: []types.Expression{nil}}, nil
+	}
+	return nil, parseErrorf(pos, "unexpected yaml-node: %s", strconv.Itoa(int(node.Kind)))
+}
+
+/* parseYAML parses a YAML-document as data */
+func parseYAML(content []byte, filename string) (types.Expression, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, &types.ParseError{Position: types.Position{Filename: filename}, Err: err}
+	}
+	return yamlExpr(&node, filename)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* uniqueOutputs returns the outputs of the last evaluation without duplicates, in order of evaluation,
+ * which is topological as outputs are recorded after their dependencies */
+func uniqueOutputs(ev *types.Evaluator) []types.OutputInfo {
+	var (
+		seen    = make(map[string]bool)
+		outputs []types.OutputInfo
+	)
+	for _, info := range ev.Outputs {
+		if !seen[info.Path] {
+			seen[info.Path] = true
+			outputs = append(outputs, info)
+		}
+	}
+	return outputs
+}
+
+func planMain(args []string) {
+	var ev types.Evaluator
+
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon plan [options] <file.zon> [key=value ...]\n")
+		flags.PrintDefaults()
+	}
+	evaluatorFlags(flags, &ev)
+	flags.Parse(args)
+
+	ev.DryRun = true
+	if _, _, err := evaluate(flags.Args(), &ev); err != nil {
+		exitError(err)
+	}
+
+	var plan []any
+	for _, info := range uniqueOutputs(&ev) {
+		depends := make([]string, len(info.Depends))
+		for i, dep := range info.Depends {
+			depends[i] = dep.Name
+		}
+		status := "build"
+		if info.Cached {
+			status = "cached"
+		}
+		plan = append(plan, map[string]any{
+			"name":    info.Name,
+			"hash":    info.Hash,
+			"path":    info.Path,
+			"status":  status,
+			"depends": depends,
+		})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	enc.Encode(plan)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* writeStats writes the statistics of the last run as JSON to `filename`,
+ * evaluation time is the wall-time of the run where no build was running */
+func writeStats(filename string, ev *types.Evaluator, wall time.Duration) error {
+	var (
+		built, cached, substituted int
+		written                    int64
+	)
+	outputs := uniqueOutputs(ev)
+	for _, info := range outputs {
+		switch {
+		case info.Cached:
+			cached++
+		case info.Substituted:
+			substituted++
+			written += diskUsage(info.Path)
+		case !ev.DryRun:
+			built++
+			written += diskUsage(info.Path)
+		}
+	}
+
+	content, err := json.MarshalIndent(map[string]any{
+		"outputs": map[string]any{
+			"evaluated":   len(outputs),
+			"built":       built,
+			"cached":      cached,
+			"substituted": substituted,
+			"failed":      ev.Stats.Failed,
+		},
+		"bytesWritten": written,
+		"time": map[string]any{
+			"total": wall.Seconds(),
+			"eval":  (wall - ev.Stats.BuildTime).Seconds(),
+			"build": ev.Stats.BuildTime.Seconds(),
+		},
+		"peakBuilds": ev.Stats.PeakBuilds,
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, content, 0644)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* absPath is a flag which is made absolute when set, outputs embed the paths of their
+ * dependencies, so the store must not depend on the directory zon was started in */
+type absPath struct {
+	dest *string
+}
+
+func (p absPath) String() string {
+	if p.dest == nil {
+		return ""
+	}
+	return *p.dest
+}
+
+func (p absPath) Set(value string) error {
+	abs, err := filepath.Abs(value)
+	if err != nil {
+		return err
+	}
+	*p.dest = abs
+	return nil
+}
+
+func (p absPath) Type() string {
+	return "path"
+}
+
+/* storeExists reports whether `storename` is in the store at `cachedir`, also as impure output */
+func storeExists(cachedir string, storename string) bool {
+	for _, name := range []string{path.Join(cachedir, storename), path.Join(cachedir, types.ImpureDir, storename)} {
+		if _, err := os.Stat(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+/* diskUsage returns the size of all files in `root` */
+func diskUsage(root string) int64 {
+	var size int64
+	filepath.WalkDir(root, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+var storeCommands = map[string]func(args []string){
+	"du": storeDuMain,
+}
+
+func storeMain(args []string) {
+	if len(args) > 0 {
+		if cmd, ok := storeCommands[args[0]]; ok {
+			cmd(args[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "usage: zon store du [options]\n")
+	os.Exit(1)
+}
+
+func storeDuMain(args []string) {
+	var (
+		ev          types.Evaluator
+		unusedSince string
+	)
+
+	flags := flag.NewFlagSet("store du", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon store du [options]\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.StringVar(&unusedSince, "unused-since", "", "only list entries which were not built or reused for `age`, e.g. 30d")
+	flags.Parse(args)
+
+	var since time.Duration
+	if unusedSince != "" {
+		var err error
+		if since, err = parseAge(unusedSince); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	entries, err := os.ReadDir(ev.CacheDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	index, err := types.ReadIndex(ev.CacheDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	type usage struct {
+		name string
+		size int64
+		used time.Time
+	}
+	var (
+		usages []usage
+		total  int64
+	)
+	for _, entry := range entries {
+		if entry.Name() == types.ImpureDir || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		used := lastUsed(ev.CacheDir, index, entry.Name())
+		if since > 0 && time.Since(used) < since {
+			continue
+		}
+		size := diskUsage(path.Join(ev.CacheDir, entry.Name()))
+		usages = append(usages, usage{entry.Name(), size, used})
+		total += size
+	}
+	slices.SortFunc(usages, func(a, b usage) int {
+		return cmp.Compare(b.size, a.size)
+	})
+	for _, u := range usages {
+		fmt.Printf("%10s  %s  %s\n", formatSize(u.size), u.used.Format(time.DateOnly), u.name)
+	}
+	fmt.Printf("%10s  total\n", formatSize(total))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+func TestStoreExists(t *testing.T) {
+	cachedir := t.TempDir()
+	os.MkdirAll(path.Join(cachedir, types.ImpureDir, "impure-name"), 0755)
+	os.MkdirAll(path.Join(cachedir, "pure-name"), 0755)
+
+	tests := []struct {
+		storename string
+		want      bool
+	}{
+		{"pure-name", true},
+		{"impure-name", true}, /* logs of impure outputs are not orphaned */
+		{"missing-name", false},
+	}
+	for _, test := range tests {
+		if got := storeExists(cachedir, test.storename); got != test.want {
+			t.Errorf("storeExists(%s) = %v, want %v", test.storename, got, test.want)
+		}
+	}
+}
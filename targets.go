@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+func targetsMain(args []string) {
+	var jsonOutput bool
+
+	flags := flag.NewFlagSet("targets", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon targets [options] <file.zon>\n")
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&jsonOutput, "json", false, "print targets as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	ast, err := parser.ParseFile(types.PathExpr{Position: types.Position{Filename: "<commandline>"}, Name: flags.Arg(0)})
+	if err != nil {
+		exitError(err)
+	}
+
+	targets := types.Targets(ast)
+	if jsonOutput {
+		entries := make([]any, len(targets))
+		for i, target := range targets {
+			entries[i] = map[string]any{
+				"name":     target.Name,
+				"position": target.Pos(),
+				"path":     strings.Join(target.Path, "."),
+			}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		enc.Encode(entries)
+		return
+	}
+	for _, target := range targets {
+		name, attrpath := target.Name, strings.Join(target.Path, ".")
+		if name == "" {
+			name = "?"
+		}
+		if attrpath == "" {
+			attrpath = "<root>"
+		}
+		fmt.Printf("%-20s %-30s %s\n", name, attrpath, target.Pos())
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+/* criticalPath returns the chain of dependent outputs with the longest total build time, from the root to the leaf */
+func criticalPath(outputs []types.OutputInfo) ([]types.OutputInfo, time.Duration) {
+	byPath := make(map[string]types.OutputInfo)
+	for _, info := range outputs {
+		byPath[info.Path] = info
+	}
+
+	type chain struct {
+		total time.Duration
+		next  string
+	}
+	memo := make(map[string]chain)
+	var longest func(p string) time.Duration
+	longest = func(p string) time.Duration {
+		if c, ok := memo[p]; ok {
+			return c.total
+		}
+		info := byPath[p]
+		best := chain{}
+		for _, dep := range info.Depends {
+			if _, ok := byPath[dep.Name]; !ok {
+				continue
+			}
+			if total := longest(dep.Name); total > best.total || best.next == "" {
+				best = chain{total, dep.Name}
+			}
+		}
+		best.total += info.Duration
+		memo[p] = best
+		return best.total
+	}
+
+	var (
+		start string
+		total time.Duration
+	)
+	for _, info := range outputs {
+		if t := longest(info.Path); t > total || start == "" {
+			start, total = info.Path, t
+		}
+	}
+
+	var path []types.OutputInfo
+	for p := start; p != ""; p = memo[p].next {
+		path = append(path, byPath[p])
+	}
+	return path, total
+}
+
+/* printTimings prints the `n` slowest outputs and the critical path */
+func printTimings(w io.Writer, outputs []types.OutputInfo, n int) {
+	sorted := slices.Clone(outputs)
+	slices.SortFunc(sorted, func(a, b types.OutputInfo) int {
+		return cmp.Compare(b.Duration, a.Duration)
+	})
+
+	fmt.Fprintln(w, "slowest outputs:")
+	for i, info := range sorted {
+		if i >= n {
+			break
+		}
+		status := ""
+		if info.Cached {
+			status = " (cached)"
+		}
+		fmt.Fprintf(w, "  %10v  %s%s\n", info.Duration.Round(time.Millisecond), path.Base(info.Path), status)
+		for _, phase := range info.Phases {
+			fmt.Fprintf(w, "  %10v    %s\n", phase.Duration.Round(time.Millisecond), phase.Name)
+		}
+	}
+
+	critical, total := criticalPath(outputs)
+	fmt.Fprintf(w, "critical path (%v):\n", total.Round(time.Millisecond))
+	for _, info := range critical {
+		fmt.Fprintf(w, "  %10v  %s\n", info.Duration.Round(time.Millisecond), path.Base(info.Path))
+	}
+}
+
+/* timingsJSON returns the durations of every output and the critical path */
+func timingsJSON(outputs []types.OutputInfo) any {
+	entries := make([]any, len(outputs))
+	for i, info := range outputs {
+		entries[i] = info.JSON()
+	}
+	critical, total := criticalPath(outputs)
+	criticalPaths := make([]string, len(critical))
+	for i, info := range critical {
+		criticalPaths[i] = info.Path
+	}
+	return map[string]any{
+		"outputs": entries,
+		"critical": map[string]any{
+			"duration": total.Seconds(),
+			"path":     criticalPaths,
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+type treePrinter struct {
+	out      io.Writer
+	maxDepth int  /* unlimited if 0 */
+	sizes    bool /* print disk usage of every path */
+
+	seen map[string]bool
+}
+
+func (t *treePrinter) label(p types.PathExpr) string {
+	label, hash := pathLabel(p)
+	if hash != "" {
+		label += " (" + hash + ")"
+	}
+	if t.sizes {
+		label += fmt.Sprintf(" [%s]", formatSize(diskUsage(p.Name)))
+	}
+	return label
+}
+
+func (t *treePrinter) print(p types.PathExpr, indent string, depth int) {
+	if t.seen == nil {
+		t.seen = make(map[string]bool)
+	}
+	for i, dep := range p.Depends {
+		branch, next := "├── ", "│   "
+		if i == len(p.Depends)-1 {
+			branch, next = "└── ", "    "
+		}
+		if t.seen[dep.Name] && len(dep.Depends) > 0 {
+			/* printed before, do not repeat its dependencies */
+			fmt.Fprintf(t.out, "%s%s%s (*)\n", indent, branch, t.label(dep))
+			continue
+		}
+		t.seen[dep.Name] = true
+		fmt.Fprintf(t.out, "%s%s%s\n", indent, branch, t.label(dep))
+		if t.maxDepth == 0 || depth+1 < t.maxDepth {
+			t.print(dep, indent+next, depth+1)
+		} else if len(dep.Depends) > 0 {
+			fmt.Fprintf(t.out, "%s%s...\n", indent, next)
+		}
+	}
+}
+
+/* PrintPathTree prints every path of `roots` and their dependencies */
+func (t *treePrinter) PrintPathTree(roots []types.PathExpr) {
+	for _, root := range roots {
+		fmt.Fprintln(t.out, t.label(root))
+		t.print(root, "", 0)
+	}
+}
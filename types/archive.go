@@ -0,0 +1,240 @@
+package types
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/* first file of an archive, listing the content-hash of every store-entry */
+type ArchiveManifest struct {
+	Root      string            `json:"root"`
+	StoreRoot string            `json:"storeRoot"` /* store the entries were built in, their content refers to it */
+	Entries   map[string]string `json:"entries"`   /* store-name to content-hash */
+}
+
+/* ContentHash returns the hash of `algorithm` of the names, permissions, contents and symlink-targets in `root` */
+func ContentHash(root string, algorithm string) (string, error) {
+	hashlib, err := NewHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	err = filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hashlib, "%s\x00%v\x00", rel, info.Mode())
+		switch {
+		case info.Mode().IsRegular():
+			file, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(hashlib, file); err != nil {
+				return err
+			}
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(hashlib, target)
+		}
+		fmt.Fprint(hashlib, "\x00")
+		return nil
+	})
+	return FormatHash(algorithm, hashlib), err
+}
+
+/* ImportArchive extracts the archive in `r` into the store at `cachedir`, entries are verified against the manifest
+ * before they are moved into the store, entries which are already in the store are skipped.
+ * Archives of another store are rejected unless `relocate` is set, as the entries refer to paths in that store,
+ * archives of another root than `root` are rejected unless it is empty.
+ * It returns the root of the archive and the names of the imported entries */
+func ImportArchive(r io.Reader, cachedir string, root string, relocate bool) (string, []string, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+
+	tmpdir, err := os.MkdirTemp(cachedir, ".import-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		/* read-only directories would keep their contents */
+		filepath.WalkDir(tmpdir, func(name string, entry fs.DirEntry, err error) error {
+			if err == nil && entry.IsDir() {
+				os.Chmod(name, 0700)
+			}
+			return nil
+		})
+		os.RemoveAll(tmpdir)
+	}()
+
+	var (
+		manifest ArchiveManifest
+		indexes  = make(map[string]IndexEntry)
+		links    = make(map[string]bool)
+		dirModes = make(map[string]fs.FileMode) /* restored after extracting, directories stay writable until then */
+		imported []string
+	)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", nil, err
+		}
+		name := path.Clean(hdr.Name)
+		if name == "manifest.json" || strings.HasPrefix(name, "index/") {
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return "", nil, err
+			}
+			if name == "manifest.json" {
+				err = json.Unmarshal(content, &manifest)
+				if err == nil && !relocate && manifest.StoreRoot != "" && manifest.StoreRoot != cachedir {
+					return "", nil, fmt.Errorf("archive was created in store %s, its entries would refer to missing paths in %s", manifest.StoreRoot, cachedir)
+				}
+			} else {
+				var entry IndexEntry
+				err = json.Unmarshal(content, &entry)
+				indexes[strings.TrimSuffix(path.Base(name), ".json")] = entry
+			}
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: %w", name, err)
+			}
+			continue
+		}
+		if !strings.HasPrefix(name, "store/") || strings.Contains(name, "..") {
+			return "", nil, fmt.Errorf("invalid path in archive: %s", hdr.Name)
+		}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if links[dir] {
+				/* writing through the symlink would escape the temporary directory */
+				return "", nil, fmt.Errorf("invalid path in archive: %s", hdr.Name)
+			}
+		}
+		target := filepath.Join(tmpdir, name)
+		/* fetched inputs are single files */
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0700)
+			dirModes[target] = fs.FileMode(hdr.Mode).Perm()
+		case tar.TypeSymlink:
+			err = os.Symlink(hdr.Linkname, target)
+			links[name] = true
+		case tar.TypeReg:
+			var file *os.File
+			file, err = os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fs.FileMode(hdr.Mode).Perm())
+			if err == nil {
+				_, err = io.Copy(file, tr)
+				file.Close()
+			}
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	/* deepest first, a parent without permission to search it would hide its children */
+	dirs := slices.SortedFunc(maps.Keys(dirModes), func(a, b string) int {
+		return strings.Count(b, string(filepath.Separator)) - strings.Count(a, string(filepath.Separator))
+	})
+	for _, dir := range dirs {
+		if err := os.Chmod(dir, dirModes[dir]); err != nil {
+			return "", nil, err
+		}
+	}
+
+	names := slices.Sorted(func(yield func(string) bool) {
+		for name := range manifest.Entries {
+			if !yield(name) {
+				return
+			}
+		}
+	})
+	if root != "" && manifest.Root != root {
+		return "", nil, fmt.Errorf("archive contains %s instead of %s", manifest.Root, root)
+	}
+	if _, ok := manifest.Entries[manifest.Root]; !ok {
+		return "", nil, fmt.Errorf("archive does not contain its root %s", manifest.Root)
+	}
+	for _, name := range names {
+		/* entries are moved to the store by their name */
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return "", nil, fmt.Errorf("invalid entry in manifest: %s", name)
+		}
+	}
+	for _, name := range names {
+		extracted := filepath.Join(tmpdir, "store", name)
+		algorithm, _, err := SplitHash(manifest.Entries[name])
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+		hash, err := ContentHash(extracted, algorithm)
+		if err != nil {
+			return "", nil, err
+		}
+		if hash != manifest.Entries[name] {
+			return "", nil, fmt.Errorf("hash mismatch for %s, expected %s, got %s", name, manifest.Entries[name], hash)
+		}
+	}
+	for _, name := range names {
+		dest := path.Join(cachedir, name)
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+		extracted := filepath.Join(tmpdir, "store", name)
+		/* moving a directory updates its `..`, which needs permission to write it */
+		mode, isDir := dirModes[extracted]
+		if isDir {
+			os.Chmod(extracted, mode|0200)
+		}
+		if err := os.Rename(extracted, dest); err != nil {
+			return "", nil, err
+		}
+		if isDir {
+			os.Chmod(dest, mode)
+		}
+		if entry, ok := indexes[name]; ok {
+			entry.Path = dest
+			entry.Content = manifest.Entries[name]
+			content, err := json.MarshalIndent(entry, "", "\t")
+			if err != nil {
+				return "", nil, err
+			}
+			os.MkdirAll(path.Join(cachedir, IndexDir), 0755)
+			if err := os.WriteFile(path.Join(cachedir, IndexDir, name+".json"), content, 0644); err != nil {
+				return "", nil, err
+			}
+		}
+		imported = append(imported, name)
+	}
+	return manifest.Root, imported, nil
+}
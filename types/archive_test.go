@@ -0,0 +1,184 @@
+package types
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/* archived file of a test archive, a symlink if `link` is set, a directory if `mode` is set */
+type archived struct {
+	name, content, link string
+	mode                int64
+}
+
+/* makeArchive packs `files` with `manifest` as created by `zon export` */
+func makeArchive(t *testing.T, manifest ArchiveManifest, files []archived) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	content, _ := json.Marshal(manifest)
+	tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tw.Write(content)
+	for _, file := range files {
+		if file.link != "" {
+			tw.WriteHeader(&tar.Header{Name: file.name, Linkname: file.link, Typeflag: tar.TypeSymlink})
+			continue
+		}
+		if file.mode != 0 {
+			tw.WriteHeader(&tar.Header{Name: file.name, Mode: file.mode, Typeflag: tar.TypeDir})
+			continue
+		}
+		tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(file.content))
+	}
+	tw.Close()
+	zw.Close()
+	return &buf
+}
+
+/* hashOf returns the content-hash of a store-entry being a single file with `content` */
+func hashOf(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "entry")
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ContentHash(name, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestImportArchive(t *testing.T) {
+	const root = "0123456789abcdefghijklmnopqrstuv-hello"
+	hello := hashOf(t, "hello\n")
+	tests := []struct {
+		name     string
+		expect   string /* root passed to ImportArchive */
+		manifest ArchiveManifest
+		files    []archived
+		err      string /* empty if the import succeeds */
+	}{
+		{"valid", root,
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello}},
+			[]archived{{name: "store/" + root, content: "hello\n"}}, ""},
+		{"any root", "",
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello}},
+			[]archived{{name: "store/" + root, content: "hello\n"}}, ""},
+		{"other root", "other",
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello}},
+			[]archived{{name: "store/" + root, content: "hello\n"}}, "instead of other"},
+		{"missing root", "",
+			ArchiveManifest{Root: "missing", Entries: map[string]string{root: hello}},
+			[]archived{{name: "store/" + root, content: "hello\n"}}, "does not contain its root"},
+		{"hash mismatch", root,
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello}},
+			[]archived{{name: "store/" + root, content: "tampered\n"}}, "hash mismatch"},
+		{"entry outside store", "",
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello, "../escape": hello}},
+			[]archived{{name: "store/" + root, content: "hello\n"}}, "invalid entry"},
+		{"nested entry", "",
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello, root + "/x": hello}},
+			[]archived{{name: "store/" + root, content: "hello\n"}}, "invalid entry"},
+		{"hidden entry", "",
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello, IndexDir: hello}},
+			[]archived{{name: "store/" + root, content: "hello\n"}}, "invalid entry"},
+		{"path outside store", "",
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello}},
+			[]archived{{name: "store/../../escape", content: "hello\n"}}, "invalid path"},
+		{"write through symlink", "",
+			ArchiveManifest{Root: root, Entries: map[string]string{root: hello}},
+			[]archived{{name: "store/" + root, link: "/tmp"}, {name: "store/" + root + "/escape", content: "hello\n"}}, "invalid path"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cachedir := t.TempDir()
+			got, imported, err := ImportArchive(makeArchive(t, test.manifest, test.files), cachedir, test.expect, false)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				entries, _ := os.ReadDir(cachedir)
+				if len(entries) > 0 {
+					t.Errorf("store is not empty after failed import: %v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != root || len(imported) != 1 || imported[0] != root {
+				t.Errorf("got root %s, imported %v", got, imported)
+			}
+			if content, err := os.ReadFile(filepath.Join(cachedir, root)); err != nil || string(content) != "hello\n" {
+				t.Errorf("got content %q, error %v", content, err)
+			}
+		})
+	}
+}
+
+func TestImportArchiveOtherStore(t *testing.T) {
+	const root = "0123456789abcdefghijklmnopqrstuv-hello"
+	manifest := ArchiveManifest{Root: root, StoreRoot: "/other/store", Entries: map[string]string{root: hashOf(t, "hello\n")}}
+	files := []archived{{name: "store/" + root, content: "hello\n"}}
+
+	if _, _, err := ImportArchive(makeArchive(t, manifest, files), t.TempDir(), "", false); err == nil || !strings.Contains(err.Error(), "created in store") {
+		t.Errorf("got error %v, want rejection of other store", err)
+	}
+	if _, _, err := ImportArchive(makeArchive(t, manifest, files), t.TempDir(), "", true); err != nil {
+		t.Errorf("unexpected error with relocate: %v", err)
+	}
+}
+
+func TestImportArchiveModes(t *testing.T) {
+	const root = "0123456789abcdefghijklmnopqrstuv-hello"
+	/* the same tree on disk for its content-hash */
+	entry := filepath.Join(t.TempDir(), "entry")
+	os.MkdirAll(filepath.Join(entry, "sub"), 0755)
+	if err := os.WriteFile(filepath.Join(entry, "sub", "file"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(filepath.Join(entry, "sub"), 0500)
+	os.Chmod(entry, 0555)
+	defer os.Chmod(filepath.Join(entry, "sub"), 0755)
+	defer os.Chmod(entry, 0755)
+	hash, err := ContentHash(entry, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* directories are listed after their contents, they are created implicitly first */
+	files := []archived{
+		{name: "store/" + root + "/sub/file", content: "hello\n"},
+		{name: "store/" + root + "/sub", mode: 0500},
+		{name: "store/" + root, mode: 0555},
+	}
+	cachedir := t.TempDir()
+	manifest := ArchiveManifest{Root: root, Entries: map[string]string{root: hash}}
+	if _, _, err := ImportArchive(makeArchive(t, manifest, files), cachedir, root, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Chmod(filepath.Join(cachedir, root, "sub"), 0755)
+	defer os.Chmod(filepath.Join(cachedir, root), 0755)
+	for name, want := range map[string]os.FileMode{root: 0555, root + "/sub": 0500} {
+		info, err := os.Stat(filepath.Join(cachedir, name))
+		if err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, error %v, want %v", name, info.Mode().Perm(), err, want)
+		}
+	}
+	if entries, _ := filepath.Glob(filepath.Join(cachedir, ".import-*")); len(entries) > 0 {
+		t.Errorf("import directory is left behind: %v", entries)
+	}
+}
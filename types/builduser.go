@@ -0,0 +1,94 @@
+package types
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/* DefaultBuildUser is the user builders run as if zon runs as root */
+const DefaultBuildUser = "nobody"
+
+/* unprivileged user builders run as */
+type buildUser struct {
+	uid, gid int
+}
+
+/* lookupBuildUser parses `name` as `uid:gid` or the name of a user, nobody is 65534 if it does not exist */
+func lookupBuildUser(name string) (*buildUser, error) {
+	if uid, gid, ok := strings.Cut(name, ":"); ok {
+		u, err := strconv.Atoi(uid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid: %s", uid)
+		}
+		g, err := strconv.Atoi(gid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid: %s", gid)
+		}
+		return &buildUser{u, g}, nil
+	}
+	entry, err := user.Lookup(name)
+	if err != nil {
+		if name == DefaultBuildUser {
+			return &buildUser{65534, 65534}, nil
+		}
+		return nil, err
+	}
+	uid, _ := strconv.Atoi(entry.Uid)
+	gid, _ := strconv.Atoi(entry.Gid)
+	return &buildUser{uid, gid}, nil
+}
+
+/* buildUser returns the user builders run as, nil if zon does not run as root or BuildUser is root,
+ * without a configured user builders run as root if the store is not accessible by DefaultBuildUser */
+func (ev *Evaluator) buildUser() (*buildUser, error) {
+	if os.Geteuid() != 0 {
+		return nil, nil
+	}
+	name := ev.BuildUser
+	if name == "" {
+		name = DefaultBuildUser
+	}
+	if name == "root" || name == "0:0" {
+		return nil, nil
+	}
+	u, err := lookupBuildUser(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := u.canAccess(ev.CacheDir); !ok {
+		if ev.BuildUser != "" {
+			return nil, fmt.Errorf("%s cannot access %s, move the store with --cache", name, dir)
+		}
+		ev.buildUserOnce.Do(func() {
+			fmt.Fprintf(os.Stderr, "warning: builders run as root, %s cannot access %s, move the store with --cache or set --build-user\n", name, dir)
+		})
+		return nil, nil
+	}
+	return u, nil
+}
+
+/* prepare gives the build user `builddir`, if not empty, and the staging directory `$out` is created in,
+ * the store itself is left untouched */
+func (u *buildUser) prepare(builddir string, staging string) error {
+	if builddir != "" {
+		if err := chownTree(builddir, u.uid, u.gid); err != nil {
+			return err
+		}
+	}
+	return os.Lchown(staging, u.uid, u.gid)
+}
+
+/* chownTree changes the owner of every file in `root` without following symlinks */
+func chownTree(root string, uid, gid int) error {
+	return filepath.WalkDir(root, func(name string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(name, uid, gid)
+	})
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package types
+
+import "os/exec"
+
+/* apply does nothing, builders run as the user of zon on this platform */
+func (u *buildUser) apply(cmd *exec.Cmd) {}
+
+/* canAccess reports true, builders run as the user of zon on this platform */
+func (u *buildUser) canAccess(dir string) (string, bool) {
+	return "", true
+}
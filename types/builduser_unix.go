@@ -0,0 +1,39 @@
+//go:build unix
+
+package types
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+/* apply makes `cmd` run as the build user */
+func (u *buildUser) apply(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(u.uid), Gid: uint32(u.gid)},
+	}
+}
+
+/* canAccess reports whether the user can traverse every parent of `dir`, otherwise the first which it cannot */
+func (u *buildUser) canAccess(dir string) (string, bool) {
+	for dir = filepath.Dir(dir); ; dir = filepath.Dir(dir) {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return dir, false
+		}
+		perm := info.Mode().Perm()
+		owner := info.Sys().(*syscall.Stat_t)
+		switch {
+		case int(owner.Uid) == u.uid && perm&0o100 != 0:
+		case int(owner.Gid) == u.gid && perm&0o010 != 0:
+		case perm&0o001 != 0:
+		default:
+			return dir, false
+		}
+		if dir == filepath.Dir(dir) {
+			return "", true
+		}
+	}
+}
@@ -0,0 +1,66 @@
+//go:build unix
+
+package types_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+)
+
+func TestBuildUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("builders only run as another user if zon runs as root")
+	}
+	tests := []struct {
+		name   string
+		script string
+		file   string /* file in $out, empty if $out is a file itself */
+	}{
+		{"file", `echo hi > $out`, ""},
+		{"directory", `mkdir -p $out/bin && echo hi > $out/bin/tool`, "bin/tool"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cachedir := t.TempDir()
+			/* the build user must be able to reach the store */
+			os.Chmod(filepath.Dir(cachedir), 0755)
+			os.Chmod(cachedir, 0755)
+			src := `output { "name": "user", "output": ` + "''" + test.script + "''" + ` }`
+			ast, err := parser.ParseString(src, filepath.Join(t.TempDir(), "test.zon"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ev := &types.Evaluator{
+				ParseFile:   parser.ParseFile,
+				CacheDir:    cachedir,
+				LogDir:      t.TempDir(),
+				Interpreter: "sh",
+				BuildUser:   "65534:65534",
+			}
+			value, _, err := ev.Resolve(ast, make(types.Scope))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			name := filepath.Join(value.(types.PathExpr).Name, test.file)
+			content, err := os.ReadFile(name)
+			if err != nil || string(content) != "hi\n" {
+				t.Fatalf("got content %q, error %v", content, err)
+			}
+			info, err := os.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner := info.Sys().(*syscall.Stat_t).Uid; owner != 0 {
+				t.Errorf("output is owned by %d, want root", owner)
+			}
+			if entries, _ := filepath.Glob(filepath.Join(cachedir, ".build-*")); len(entries) > 0 {
+				t.Errorf("staging directory is left behind: %v", entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+)
+
+type BuiltinFunc func(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error)
+
+/* functions available in every scope, unless shadowed */
+var builtins = map[string]BuiltinFunc{
+	"compare":        builtinCompare,
+	"contains":       builtinContains,
+	"currentTime":    builtinCurrentTime,
+	"elem":           builtinElem,
+	"equals":         builtinEquals,
+	"fetch":          builtinFetch,
+	"fetchGit":       builtinFetchGit,
+	"filterAttrs":    builtinFilterAttrs,
+	"filterSource":   builtinFilterSource,
+	"flatten":        builtinFlatten,
+	"intersectAttrs": builtinIntersectAttrs,
+	"length":         builtinLength,
+	"padStart":       builtinPadStart,
+	"placeholder":    builtinPlaceholder,
+	"randomString":   builtinRandomString,
+	"sort":           builtinSort,
+	"subpath":        builtinSubpath,
+	"substring":      builtinSubstring,
+	"toFixed":        builtinToFixed,
+	"toHex":          builtinToHex,
+	"unique":         builtinUnique,
+	"unzip":          builtinUnzip,
+	"uuid":           builtinUUID,
+	"zip":            builtinZip,
+}
+
+type BuiltinExpr struct {
+	Position
+
+	Name string
+	Func BuiltinFunc
+}
+
+func (obj BuiltinExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	return obj, nil, nil
+}
+
+func (obj BuiltinExpr) hashValue(w io.Writer) {
+	fmt.Fprint(w, "builtin")
+	fmt.Fprint(w, obj.Name)
+}
+
+func (obj BuiltinExpr) encodeEnviron(root bool) (string, error) {
+	return "", evalErrorf(obj.Position, "unable to encode %T to environment", obj)
+}
+
+func (obj BuiltinExpr) Link(resultname string) error {
+	return evalErrorf(obj.Position, "unable to link %T", obj)
+}
+
+func (obj BuiltinExpr) JSON() any {
+	return nil
+}
+
+func (obj BuiltinExpr) Boolean() (bool, error) {
+	return false, evalErrorf(obj.Position, "builtins do not have an boolean expression")
+}
+
+/* expectArgs checks whether `call` got between `min` and `max` arguments */
+func expectArgs(call CallExpr, args []Value, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return evalErrorf(call.Position, "function expecting %d arguments, got %d", min, len(args))
+		}
+		return evalErrorf(call.Position, "function expecting %d to %d arguments, got %d", min, max, len(args))
+	}
+	return nil
+}
+
+/* argValue asserts argument `i` of `call` to be of type T */
+func argValue[T Value](call CallExpr, args []Value, i int) (ret T, err error) {
+	value, ok := args[i].(T)
+	if !ok {
+		return ret, evalErrorf(args[i].Location(), "argument %d should be a %T, got %T", i+1, ret, args[i])
+	}
+	return value, nil
+}
+
+/* callFunction calls the lambda or builtin `fn` with already resolved `args` for `call` in `scope` */
+func callFunction(call CallExpr, fn Value, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	switch fn := fn.(type) {
+	case BuiltinExpr:
+		return fn.Func(call, args, scope, ev)
+	case LambdaExpr:
+		if fn.Attrs {
+			if len(args) != 1 {
+				return nil, nil, evalErrorf(fn.Position, "function expecting a map, got %d arguments", len(args))
+			}
+			newscope := maps.Clone(scope)
+			if err := fn.bindAttrs(call, args[0], scope, newscope); err != nil {
+				return nil, nil, err
+			}
+			return ev.Resolve(fn.Expr, newscope)
+		}
+		if len(fn.Args) != len(args) {
+			return nil, nil, evalErrorf(fn.Position, "function expecting %d arguments, got %d", len(fn.Args), len(args))
+		}
+		newscope := maps.Clone(scope)
+		for i, name := range fn.Args {
+			newscope[name] = Variable{resolvedExpr{args[i], nil}, scope}
+		}
+		return ev.Resolve(fn.Expr, newscope)
+	}
+	return nil, nil, evalErrorf(fn.Location(), "unable to call %T", fn)
+}
+
+/* argInt asserts argument `i` of `call` to be a whole number */
+func argInt(call CallExpr, args []Value, i int) (int, error) {
+	num, err := argValue[NumberExpr](call, args, i)
+	if err != nil {
+		return 0, err
+	}
+	if num.Value != math.Trunc(num.Value) {
+		return 0, evalErrorf(num.Position, "argument %d should be a whole number, got %v", i+1, num.Value)
+	}
+	return int(num.Value), nil
+}
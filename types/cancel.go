@@ -0,0 +1,55 @@
+package types
+
+import (
+	"errors"
+	"os/exec"
+)
+
+/* ErrCancelled is returned by builders which were stopped or not started as another builder failed */
+var ErrCancelled = errors.New("cancelled as another builder failed")
+
+/* startBuilder starts `cmd` in its own process-group, unless the builds are cancelled */
+func (ev *Evaluator) startBuilder(cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	ev.buildersMu.Lock()
+	defer ev.buildersMu.Unlock()
+	if ev.cancelled {
+		return ErrCancelled
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if ev.builders == nil {
+		ev.builders = make(map[*exec.Cmd]bool)
+	}
+	ev.builders[cmd] = true
+	return nil
+}
+
+/* waitBuilder waits for `cmd` started by startBuilder, a builder killed by cancelBuilds returns ErrCancelled */
+func (ev *Evaluator) waitBuilder(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	ev.buildersMu.Lock()
+	defer ev.buildersMu.Unlock()
+	delete(ev.builders, cmd)
+	if err != nil && ev.cancelled {
+		return ErrCancelled
+	}
+	return err
+}
+
+/* cancelBuilds kills the process-groups of running builders and prevents new ones from starting, unless KeepGoing */
+func (ev *Evaluator) cancelBuilds() {
+	if ev.KeepGoing {
+		return
+	}
+	ev.buildersMu.Lock()
+	defer ev.buildersMu.Unlock()
+	if ev.cancelled {
+		return
+	}
+	ev.cancelled = true
+	for cmd := range ev.builders {
+		killProcessGroup(cmd)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package types
+
+import "os/exec"
+
+/* setProcessGroup does nothing, there are no process-groups on this platform */
+func setProcessGroup(cmd *exec.Cmd) {}
+
+/* killProcessGroup kills the started `cmd`, its children keep running on this platform */
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package types
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+/* setProcessGroup makes `cmd` run in a new process-group, so its children can be killed with it */
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+/* killProcessGroup kills the started `cmd` and every process in its group */
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
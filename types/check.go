@@ -0,0 +1,96 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/* treeEntry describes a file in an output, as compared by --check */
+type treeEntry struct {
+	mode    fs.FileMode
+	content []byte /* content of regular files, target of symlinks */
+}
+
+func readTree(root string) (map[string]treeEntry, error) {
+	entries := make(map[string]treeEntry)
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		ent := treeEntry{mode: info.Mode()}
+		switch {
+		case info.Mode().IsRegular():
+			ent.content, err = os.ReadFile(name)
+		case info.Mode()&fs.ModeSymlink != 0:
+			var target string
+			target, err = os.Readlink(name)
+			ent.content = []byte(target)
+		}
+		entries[rel] = ent
+		return err
+	})
+	return entries, err
+}
+
+/* diffTrees returns the files which differ between `a` and `b`, prefixed by + if only in `b`, - if only in `a` and ~ if changed */
+func diffTrees(a string, b string) ([]string, error) {
+	atree, err := readTree(a)
+	if err != nil {
+		return nil, err
+	}
+	btree, err := readTree(b)
+	if err != nil {
+		return nil, err
+	}
+	var diff []string
+	for name, aent := range atree {
+		bent, ok := btree[name]
+		switch {
+		case !ok:
+			diff = append(diff, "- "+name)
+		case aent.mode != bent.mode:
+			diff = append(diff, fmt.Sprintf("~ %s (mode %v, got %v)", name, aent.mode, bent.mode))
+		case !bytes.Equal(aent.content, bent.content):
+			diff = append(diff, "~ "+name)
+		}
+	}
+	for name := range btree {
+		if _, ok := atree[name]; !ok {
+			diff = append(diff, "+ "+name)
+		}
+	}
+	slices.SortFunc(diff, func(x, y string) int {
+		return strings.Compare(x[2:], y[2:])
+	})
+	return diff, nil
+}
+
+/* check rebuilds the cached output at `outdir` and compares it to the stored one */
+func (obj OutputExpr) check(result MapValue, outdir string, hashstr string, ev *Evaluator) error {
+	scratch := outdir + ".check"
+	defer os.RemoveAll(scratch)
+	if _, err := obj.build(result, scratch, hashstr+".check", ev); err != nil {
+		return err
+	}
+	diff, err := diffTrees(outdir, scratch)
+	if err != nil {
+		return evalErrorf(obj.Position, "unable to compare %s: %w", hashstr, err)
+	}
+	if len(diff) > 0 {
+		return evalErrorf(obj.Position, "%s is not reproducible, rebuild differs in:\n  %s", hashstr, strings.Join(diff, "\n  "))
+	}
+	return nil
+}
@@ -5,45 +5,34 @@ import (
 	"fmt"
 	"io"
 	"maps"
+	"slices"
 	"strings"
 	"sync"
 )
 
+/* parallelResolve resolves `exprs`, dependencies are returned in the order of `exprs` regardless of which finishes first */
 func parallelResolve(exprs []Expression, scope Scope, ev *Evaluator) ([]Value, []PathExpr, error) {
 	var (
 		values = make([]Value, len(exprs))
 		errs   = make([]error, len(exprs))
-		deps   = make([]PathExpr, 0, len(exprs))
+		paths  = make([][]PathExpr, len(exprs))
 	)
 	if !ev.Serial {
-		var (
-			wg sync.WaitGroup
-			mu sync.Mutex
-		)
-		mu.Lock()
+		var wg sync.WaitGroup
 		for i, v := range exprs {
 			wg.Add(1)
 			go func() {
-				val, paths, err := v.Resolve(scope, ev)
-				mu.Lock()
-				values[i] = val
-				errs[i] = err
-				deps = append(deps, paths...)
-				mu.Unlock()
+				values[i], paths[i], errs[i] = ev.Resolve(v, scope)
 				wg.Done()
 			}()
 		}
-		mu.Unlock()
 		wg.Wait()
 	} else {
 		for i, v := range exprs {
-			val, paths, err := v.Resolve(scope, ev)
-			values[i] = val
-			errs[i] = err
-			deps = append(deps, paths...)
+			values[i], paths[i], errs[i] = ev.Resolve(v, scope)
 		}
 	}
-	return values, deps, errors.Join(errs...)
+	return values, slices.Concat(paths...), errors.Join(errs...)
 }
 
 type MapExpr struct {
@@ -51,10 +40,20 @@ type MapExpr struct {
 
 	Extends []Expression
 	Exprs   []Expression
+	Docs    []string /* doc comment of every key, may be shorter than the keys */
+
+	Recursive bool /* values may reference the other keys, `rec { ... }` */
 }
 
 func (obj MapExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
-	values, deps, err := parallelResolve(obj.Exprs, scope, ev)
+	inner := scope
+	if obj.Recursive {
+		var err error
+		if inner, err = obj.recScope(scope); err != nil {
+			return nil, nil, err
+		}
+	}
+	values, deps, err := parallelResolve(obj.Exprs, inner, ev)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -68,21 +67,30 @@ func (obj MapExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error
 		key, value := values[i], values[i+1]
 		keyStr, ok := key.(StringValue)
 		if !ok {
-			return nil, nil, fmt.Errorf("%s: expected string-key, got %T", key.Pos(), key)
+			return nil, nil, evalErrorf(key.Location(), "expected string-key, got %T", key)
 		}
-		res.Values[keyStr.Content] = value
+		if _, ok := res.Values[keyStr.Content]; ok {
+			ev.warnf(key.Location(), "key %s is defined more than once", keyStr.Content)
+		}
+		res.set(keyStr.Content, value)
 	}
 
+	explicit := maps.Clone(res.Values)
 	for _, extname := range obj.Extends {
-		othervalue, otherdeps, err := extname.Resolve(scope, ev)
+		othervalue, otherdeps, err := ev.Resolve(extname, scope)
 		if err != nil {
 			return nil, nil, err
 		}
 		otherast, ok := othervalue.(MapValue)
 		if !ok {
-			return nil, nil, fmt.Errorf("%s: unable to extend %T", obj.Pos(), othervalue)
+			return nil, nil, evalErrorf(obj.Position, "unable to extend %T", othervalue)
+		}
+		for _, key := range otherast.Ordered() {
+			if _, ok := explicit[key]; ok {
+				ev.warnf(extname.Location(), "extended map overrides explicit key %s", key)
+			}
+			res.set(key, otherast.Values[key])
 		}
-		maps.Copy(res.Values, otherast.Values)
 		deps = append(deps, otherdeps...)
 	}
 
@@ -91,6 +99,9 @@ func (obj MapExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error
 
 func (obj MapExpr) hashValue(w io.Writer) {
 	fmt.Fprint(w, "map")
+	if obj.Recursive {
+		fmt.Fprint(w, "rec")
+	}
 	for _, k := range obj.Extends {
 		k.hashValue(w)
 	}
@@ -103,27 +114,32 @@ type MapValue struct {
 	Position
 
 	Values map[string]Value
+	Keys   []string /* keys in the order they were defined */
 }
 
 func (obj MapValue) JSON() any {
-	result := make(map[string]any)
+	result := OrderedMap{
+		Keys:   obj.Ordered(),
+		Values: make(map[string]any),
+	}
 	for k, v := range obj.Values {
-		result[k] = v.JSON()
+		result.Values[k] = v.JSON()
 	}
 	return result
 }
 
 func (obj MapValue) Link(string) error {
-	return fmt.Errorf("%s: unable to symlink object of type: %T", obj.Pos(), obj)
+	return evalErrorf(obj.Position, "unable to symlink object of type: %T", obj)
 }
 
 func (obj MapValue) encodeEnviron(root bool) (string, error) {
 	if !root {
-		return "", fmt.Errorf("%s: unable to encode nested %T", obj.Pos(), obj.Values)
+		return "", evalErrorf(obj.Position, "unable to encode nested %T", obj.Values)
 	}
 	var builder strings.Builder
 	first := true
-	for key, elem := range obj.Values {
+	for _, key := range obj.Ordered() {
+		elem := obj.Values[key]
 		if !first {
 			builder.WriteByte(' ')
 		}
@@ -186,7 +202,7 @@ func (obj ArrayExpr) hashValue(w io.Writer) {
 
 func (obj ArrayValue) encodeEnviron(root bool) (string, error) {
 	if !root {
-		return "", fmt.Errorf("%s: unable to encode nested %T", obj.Pos(), obj.Values)
+		return "", evalErrorf(obj.Position, "unable to encode nested %T", obj.Values)
 	}
 	var builder strings.Builder
 	for i, elem := range obj.Values {
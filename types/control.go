@@ -4,41 +4,80 @@ import (
 	"fmt"
 	"io"
 	"maps"
+	"path"
+	"slices"
+	"strings"
 )
 
 type IncludeExpr struct {
 	Position
 
 	Name Expression
+
+	overrides []attrOverride
 }
 
 func (obj IncludeExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
-	pathAny, deps, err := obj.Name.Resolve(scope, ev)
+	pathAny, deps, err := ev.Resolve(obj.Name, scope)
 	if err != nil {
 		return nil, nil, err
 	}
-	path, ok := pathAny.(PathExpr)
-	if !ok {
-		return nil, nil, fmt.Errorf("%s: unable to include non-path: %T", obj.Pos(), path)
+	var filename PathExpr
+	switch name := pathAny.(type) {
+	case PathExpr:
+		filename = name
+	case StringValue:
+		url, ok := ev.remoteURL(name.Content)
+		if !ok {
+			return nil, nil, evalErrorf(obj.Position, "unable to include %s: neither an url nor a known registry", name.Content)
+		}
+		filename, err = ev.fetchURL(obj.Position, name.Content, path.Base(url), url, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		deps = append(deps, filename)
+	default:
+		return nil, nil, evalErrorf(obj.Position, "unable to include non-path: %T", pathAny)
 	}
-	expr, err := ev.ParseFile(path)
+	expr, err := ev.ParseFile(filename)
 	if err != nil {
 		return nil, nil, err
 	}
-	val, paths, err := expr.Resolve(scope, ev)
+	for _, over := range obj.overrides {
+		expr, err = Override(expr, over.path, over.value)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	val, paths, err := ev.Resolve(expr, FileScope(scope, filename.Name))
 	deps = append(deps, paths...)
 	return val, deps, err
 }
 
+/* FileScope returns `scope` with `__file__` and `__dir__` set to the path of the evaluated file and its directory */
+func FileScope(scope Scope, filename string) Scope {
+	newscope := maps.Clone(scope)
+	pos := Position{Filename: filename}
+	for name, value := range map[string]string{"__file__": filename, "__dir__": path.Dir(filename)} {
+		newscope[name] = Variable{resolvedExpr{PathExpr{Position: pos, Name: value}, nil}, scope}
+	}
+	return newscope
+}
+
 func (obj IncludeExpr) hashValue(w io.Writer) {
 	fmt.Fprintf(w, "include")
 	obj.Name.hashValue(w)
+	for _, over := range obj.overrides {
+		fmt.Fprint(w, over.path)
+		over.value.hashValue(w)
+	}
 }
 
 type DefineExpr struct {
 	Position
 
 	Define map[string]Expression
+	Docs   map[string]string /* doc comments of the definitions */
 	Expr   Expression
 }
 
@@ -49,9 +88,15 @@ func (obj DefineExpr) JSON() any {
 func (obj DefineExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
 	newscope := maps.Clone(scope)
 	for name, expr := range obj.Define {
+		if _, ok := scope[name]; ok && !inherited(name, expr) {
+			ev.warnf(expr.Location(), "%s shadows a variable in scope", name)
+		}
+		if !references(obj.Expr, name) {
+			ev.warnf(expr.Location(), "%s is defined but not used", name)
+		}
 		newscope[name] = Variable{expr, scope}
 	}
-	return obj.Expr.Resolve(newscope, ev)
+	return ev.Resolve(obj.Expr, newscope)
 }
 
 func (obj DefineExpr) hashValue(w io.Writer) {
@@ -63,11 +108,123 @@ func (obj DefineExpr) hashValue(w io.Writer) {
 	obj.Expr.hashValue(w)
 }
 
+type InputsExpr struct {
+	Position
+
+	Inputs Expression
+	Expr   Expression
+}
+
+/* fetchInput fetches input `name`, described by either an url or a map with `url` and `hash`, or `git` and `rev` */
+func (obj InputsExpr) fetchInput(name string, value Value, ev *Evaluator) (PathExpr, error) {
+	switch value := value.(type) {
+	case StringValue:
+		if url, ok := strings.CutPrefix(value.Content, "git+"); ok {
+			return ev.fetchGit(value.Position, name, url, "")
+		}
+		return ev.fetchURL(value.Position, name, name, value.Content, "")
+	case MapValue:
+		var attrs [3]string
+		for i, key := range []string{"url", "git", "hash"} {
+			if _, ok := value.Values[key]; ok {
+				attr, err := getValue[StringValue]("input", value, key)
+				if err != nil {
+					return PathExpr{}, err
+				}
+				attrs[i] = attr.Content
+			}
+		}
+		url, git, hash := attrs[0], attrs[1], attrs[2]
+		if git != "" {
+			rev := ""
+			if _, ok := value.Values["rev"]; ok {
+				revValue, err := getValue[StringValue]("input", value, "rev")
+				if err != nil {
+					return PathExpr{}, err
+				}
+				rev = revValue.Content
+			}
+			return ev.fetchGit(value.Position, name, git, rev)
+		}
+		if url != "" {
+			return ev.fetchURL(value.Position, name, name, url, hash)
+		}
+		return PathExpr{}, evalErrorf(value.Location(), "input %s has neither url nor git", name)
+	}
+	return PathExpr{}, evalErrorf(value.Location(), "unable to fetch input %s from %T", name, value)
+}
+
+func (obj InputsExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	inputsAny, deps, err := ev.Resolve(obj.Inputs, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	inputs, ok := inputsAny.(MapValue)
+	if !ok {
+		return nil, nil, evalErrorf(obj.Position, "inputs should be a map, got %T", inputsAny)
+	}
+
+	newscope := maps.Clone(scope)
+	for name, value := range inputs.Values {
+		input, err := obj.fetchInput(name, value, ev)
+		if err != nil {
+			return nil, nil, err
+		}
+		newscope[name] = Variable{resolvedExpr{input, []PathExpr{input}}, scope}
+	}
+	val, paths, err := ev.Resolve(obj.Expr, newscope)
+	return val, append(deps, paths...), err
+}
+
+func (obj InputsExpr) hashValue(w io.Writer) {
+	fmt.Fprintf(w, "inputs")
+	obj.Inputs.hashValue(w)
+	obj.Expr.hashValue(w)
+}
+
 type LambdaExpr struct {
 	Position
 
 	Args []string
 	Expr Expression
+
+	Attrs    bool                  /* `fn { a, b = default } ...` takes a map whose keys are bound to Args */
+	Defaults map[string]Expression /* values of Args missing in the map */
+}
+
+/* bindAttrs binds the entries of the map `arg` to the arguments of `obj` in `newscope`,
+ * unexpected and missing keys are reported at `call` */
+func (obj LambdaExpr) bindAttrs(call CallExpr, arg Value, scope Scope, newscope Scope) error {
+	attrs, ok := arg.(MapValue)
+	if !ok {
+		return evalErrorf(call.Position, "function expecting a map, got %T", arg)
+	}
+	var problems []string
+	var unexpected, missing []string
+	for _, key := range attrs.Ordered() {
+		if !slices.Contains(obj.Args, key) {
+			unexpected = append(unexpected, key)
+		}
+	}
+	for _, name := range obj.Args {
+		if value, ok := attrs.Values[name]; ok {
+			newscope[name] = Variable{resolvedExpr{value, nil}, scope}
+		} else if def, ok := obj.Defaults[name]; ok {
+			newscope[name] = Variable{def, scope}
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(unexpected) > 0 {
+		problems = append(problems, "unexpected keys: "+strings.Join(unexpected, ", "))
+	}
+	if len(missing) > 0 {
+		problems = append(problems, "missing keys: "+strings.Join(missing, ", "))
+	}
+	if len(problems) > 0 {
+		return evalErrorf(call.Position, "invalid argument of function at %s, %s", obj.Pos(), strings.Join(problems, "; "))
+	}
+	return nil
 }
 
 func (obj LambdaExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
@@ -76,18 +233,24 @@ func (obj LambdaExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, er
 
 func (obj LambdaExpr) hashValue(w io.Writer) {
 	fmt.Fprint(w, "fn")
+	if obj.Attrs {
+		fmt.Fprint(w, "attrs")
+	}
 	for _, a := range obj.Args {
 		fmt.Fprint(w, a)
+		if def, ok := obj.Defaults[a]; ok {
+			def.hashValue(w)
+		}
 	}
 	obj.Expr.hashValue(w)
 }
 
 func (obj LambdaExpr) encodeEnviron(root bool) (string, error) {
-	return "", fmt.Errorf("%s: unable to encode %T to environment", obj.Pos(), obj)
+	return "", evalErrorf(obj.Position, "unable to encode %T to environment", obj)
 }
 
 func (obj LambdaExpr) Link(resultname string) error {
-	return fmt.Errorf("%s: unable to link %T", obj.Pos(), obj)
+	return evalErrorf(obj.Position, "unable to link %T", obj)
 }
 
 func (obj LambdaExpr) JSON() any {
@@ -95,7 +258,7 @@ func (obj LambdaExpr) JSON() any {
 }
 
 func (obj LambdaExpr) Boolean() (bool, error) {
-	return false, fmt.Errorf("lamba's do not have an boolean expression")
+	return false, evalErrorf(obj.Position, "lamba's do not have an boolean expression")
 }
 
 type ConditionExpr struct {
@@ -111,7 +274,7 @@ func (obj ConditionExpr) JSON() any {
 }
 
 func (obj ConditionExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
-	cond, deps, err := obj.Cond.Resolve(scope, ev)
+	cond, deps, err := ev.Resolve(obj.Cond, scope)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -125,7 +288,7 @@ func (obj ConditionExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr,
 	} else {
 		expr = obj.Falsy
 	}
-	val, vdeps, err := expr.Resolve(scope, ev)
+	val, vdeps, err := ev.Resolve(expr, scope)
 	return val, append(deps, vdeps...), err
 }
 
@@ -149,7 +312,66 @@ func (obj OperationExpr) JSON() any {
 }
 
 func (obj OperationExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
-	return nil, nil, fmt.Errorf("not implemented")
+	if obj.Operator == "or" {
+		return obj.fallback(scope, ev)
+	}
+	if obj.Operator != "+" {
+		return nil, nil, evalErrorf(obj.Position, "not implemented")
+	}
+	values, deps, err := parallelResolve([]Expression{obj.Left, obj.Right}, scope, ev)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch left := values[0].(type) {
+	case PathExpr:
+		/* `path + "/file"` is a path inside of `path`, depending on the same outputs */
+		if right, ok := values[1].(StringValue); ok {
+			name := path.Clean(left.Name + right.Content)
+			if name != left.Name && !strings.HasPrefix(name, strings.TrimSuffix(left.Name, "/")+"/") {
+				return nil, nil, evalErrorf(obj.Position, "%s is outside of %s", name, left.Name)
+			}
+			left.Name = name
+			return left, deps, nil
+		}
+	case StringValue:
+		if right, ok := values[1].(StringValue); ok {
+			return StringValue{obj.Position, left.Content + right.Content}, deps, nil
+		}
+	case NumberExpr:
+		if right, ok := values[1].(NumberExpr); ok {
+			return NumberExpr{obj.Position, left.Value + right.Value}, deps, nil
+		}
+	}
+	return nil, nil, evalErrorf(obj.Position, "unable to add %T and %T", values[0], values[1])
+}
+
+/* fallback resolves `left or right` to `right` if `left` is null or selects a missing attribute */
+func (obj OperationExpr) fallback(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	val, deps, err := ev.Resolve(optionalChain(obj.Left), scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := val.(NullExpr); !ok {
+		return val, deps, nil
+	}
+	return ev.Resolve(obj.Right, scope)
+}
+
+/* optionalChain returns `expr` with every attribute-selection of the chain made optional like `a.?b.?c` */
+func optionalChain(expr Expression) Expression {
+	switch expr := expr.(type) {
+	case AttributeExpr:
+		expr.Base = optionalChain(expr.Base)
+		expr.Optional = true
+		return expr
+	case OperationExpr:
+		if expr.Operator == "or" {
+			/* `a or b or c` is `(a or b) or c`, `b` may be missing as well */
+			expr.Right = optionalChain(expr.Right)
+		}
+		return expr
+	}
+	return expr
 }
 
 func (obj OperationExpr) hashValue(w io.Writer) {
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+/* copyTree copies the files, directories and symlinks in `src` into `dest`, keeping their permissions */
+func copyTree(src string, dest string) error {
+	return copyTreeFunc(src, dest, nil)
+}
+
+/* copyTreeFunc copies the entries of `src` for which `filter` returns true into `dest`, directories which are
+ * rejected are skipped entirely, everything is copied if `filter` is nil */
+func copyTreeFunc(src string, dest string, filter func(name string, entry fs.DirEntry) (bool, error)) error {
+	return filepath.WalkDir(src, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filter != nil && name != src {
+			keep, err := filter(name, entry)
+			if err != nil {
+				return err
+			}
+			if !keep && entry.IsDir() {
+				return filepath.SkipDir
+			} else if !keep {
+				return nil
+			}
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(name)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(name, target, info.Mode().Perm())
+		}
+		/* devices, sockets and pipes are skipped */
+		return nil
+	})
+}
+
+func copyFile(src string, dest string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
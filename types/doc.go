@@ -0,0 +1,73 @@
+package types
+
+import "strings"
+
+/* documentation of an attribute */
+type DocEntry struct {
+	Position
+	Name string
+	Doc  string
+	Args []string /* arguments if the attribute is a lambda */
+	Fn   bool
+}
+
+/* Doc returns the doc comment of the `i`th key */
+func (obj MapExpr) Doc(i int) string {
+	if i >= len(obj.Docs) {
+		return ""
+	}
+	return obj.Docs[i]
+}
+
+/* Documentation returns the documented attributes and lambdas of the map `expr` evaluates to,
+ * without resolving it, attributes referring to a definition of a surrounding let use its documentation */
+func Documentation(expr Expression) []DocEntry {
+	defines := make(map[string]Expression)
+	docs := make(map[string]string)
+	for {
+		switch obj := expr.(type) {
+		case DefineExpr:
+			for name, value := range obj.Define {
+				defines[name] = value
+				docs[name] = obj.Docs[name]
+			}
+			expr = obj.Expr
+			continue
+		case InputsExpr:
+			expr = obj.Expr
+			continue
+		}
+		break
+	}
+	obj, ok := expr.(MapExpr)
+	if !ok {
+		return nil
+	}
+	var entries []DocEntry
+	for i := 0; i+1 < len(obj.Exprs); i += 2 {
+		name, ok := ConstantKey(obj.Exprs[i])
+		if !ok {
+			continue
+		}
+		entry := DocEntry{
+			Position: obj.Exprs[i].Location(),
+			Name:     name,
+			Doc:      strings.TrimSpace(obj.Doc(i / 2)),
+		}
+		value := obj.Exprs[i+1]
+		if ref, ok := value.(VarExpr); ok && len(ref.Args) == 0 && defines[ref.Name] != nil {
+			value = defines[ref.Name]
+			if entry.Doc == "" {
+				entry.Doc = strings.TrimSpace(docs[ref.Name])
+			}
+		}
+		if fn, ok := value.(LambdaExpr); ok {
+			entry.Fn = true
+			entry.Args = fn.Args
+		}
+		if entry.Doc != "" || entry.Fn {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"encoding/json"
+	"maps"
+	"strconv"
+)
+
+/* outputEnviron returns the variables passed to the builder of `result`, which are the entries of `env`,
+ * with LegacyEnv every other attribute is passed as well */
+func (ev *Evaluator) outputEnviron(result MapValue) map[string]Value {
+	vars := make(map[string]Value)
+	if ev.LegacyEnv {
+		maps.Copy(vars, result.Values)
+		delete(vars, "env")
+	}
+	if env, ok := result.Values["env"].(MapValue); ok {
+		maps.Copy(vars, env.Values)
+	}
+	return vars
+}
+
+/* EncodeVariable encodes `value` to one or more environment-variables
+ * depending on `encoding`:
+ *   plain:  nested arrays and maps are rejected, the default
+ *   json:   nested arrays and maps are encoded as JSON
+ *   prefix: nested values are flattened to KEY_sub and KEY_0 variables */
+func EncodeVariable(key string, value Value, encoding string) ([]string, error) {
+	switch encoding {
+	case "plain":
+		enc, err := value.encodeEnviron(true)
+		if err != nil {
+			return nil, err
+		}
+		return []string{key + "=" + enc}, nil
+	case "json":
+		switch value.(type) {
+		case MapValue, ArrayValue:
+			enc, err := json.Marshal(value.JSON())
+			if err != nil {
+				return nil, evalErrorf(value.Location(), "unable to encode %s as json: %w", key, err)
+			}
+			return []string{key + "=" + string(enc)}, nil
+		}
+		return EncodeVariable(key, value, "plain")
+	case "prefix":
+		var vars []string
+		switch value := value.(type) {
+		case MapValue:
+			for _, name := range value.Ordered() {
+				sub, err := EncodeVariable(key+"_"+name, value.Values[name], encoding)
+				if err != nil {
+					return nil, err
+				}
+				vars = append(vars, sub...)
+			}
+		case ArrayValue:
+			for i, elem := range value.Values {
+				sub, err := EncodeVariable(key+"_"+strconv.Itoa(i), elem, encoding)
+				if err != nil {
+					return nil, err
+				}
+				vars = append(vars, sub...)
+			}
+			vars = append(vars, key+"_len="+strconv.Itoa(len(value.Values)))
+		default:
+			return EncodeVariable(key, value, "plain")
+		}
+		return vars, nil
+	}
+	return nil, evalErrorf(value.Location(), "unknown environment-encoding: %s", encoding)
+}
@@ -0,0 +1,44 @@
+package types
+
+/* equalValues compares `a` and `b` deeply, maps are equal regardless of the order of their keys,
+ * values of different types and functions are never equal */
+func equalValues(a, b Value) bool {
+	switch a := a.(type) {
+	case NullExpr:
+		_, ok := b.(NullExpr)
+		return ok
+	case ArrayValue:
+		b, ok := b.(ArrayValue)
+		if !ok || len(a.Values) != len(b.Values) {
+			return false
+		}
+		for i := range a.Values {
+			if !equalValues(a.Values[i], b.Values[i]) {
+				return false
+			}
+		}
+		return true
+	case MapValue:
+		b, ok := b.(MapValue)
+		if !ok || len(a.Values) != len(b.Values) {
+			return false
+		}
+		for key, elem := range a.Values {
+			other, ok := b.Values[key]
+			if !ok || !equalValues(elem, other) {
+				return false
+			}
+		}
+		return true
+	}
+	order, err := compareValues(a, b)
+	return err == nil && order == 0
+}
+
+/* equals(a, b) returns whether `a` and `b` are deeply equal */
+func builtinEquals(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	return BooleanExpr{call.Position, equalValues(args[0], args[1])}, nil, nil
+}
@@ -0,0 +1,63 @@
+package types
+
+import "fmt"
+
+/* ParseError is returned if a source could not be parsed */
+type ParseError struct {
+	Position
+	End int /* column after the offending token, 0 if unknown */
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Pos(), e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+/* EvalError is returned if an expression could not be evaluated */
+type EvalError struct {
+	Position
+	Err error
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Pos(), e.Err)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+/* evalErrorf returns an EvalError at `pos`, `format` is formatted as in fmt.Errorf */
+func evalErrorf(pos Position, format string, args ...any) error {
+	return &EvalError{Position: pos, Err: fmt.Errorf(format, args...)}
+}
+
+/* BuildError is returned if the builder of an output failed */
+type BuildError struct {
+	Position
+	Hash    string /* store-name of the output */
+	LogPath string
+	Tail    string /* last lines of the log */
+	Err     error
+}
+
+func (e *BuildError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Pos(), e.Message())
+	if e.Tail != "" {
+		msg += "\n" + e.Tail
+	}
+	return msg
+}
+
+/* Message describes the failure without position and log-tail */
+func (e *BuildError) Message() string {
+	return fmt.Sprintf("building %s failed, for logs look in %s: %v", e.Hash, e.LogPath, e.Err)
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
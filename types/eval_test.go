@@ -0,0 +1,276 @@
+package types_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+)
+
+/* evalString evaluates `src` as file /src/test.zon without building outputs */
+func evalString(t *testing.T, src string) (types.Value, error) {
+	t.Helper()
+	ast, err := parser.ParseString(src, "/src/test.zon")
+	if err != nil {
+		return nil, err
+	}
+	return evalExpr(t, ast)
+}
+
+/* evalExpr resolves `ast` without building outputs */
+func evalExpr(t *testing.T, ast types.Expression) (types.Value, error) {
+	t.Helper()
+	ev := &types.Evaluator{
+		ParseFile: parser.ParseFile,
+		CacheDir:  t.TempDir(),
+		LogDir:    t.TempDir(),
+		DryRun:    true,
+	}
+	value, _, err := ev.Resolve(ast, make(types.Scope))
+	return value, err
+}
+
+/* evalTest is a source evaluating to a value with JSON `want`, or failing with an error containing `err` */
+type evalTest struct {
+	name string
+	src  string
+	want any
+	err  string
+}
+
+func runEvalTests(t *testing.T, tests []evalTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := evalString(t, test.src)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := value.JSON(); !equalJSON(got, test.want) {
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+/* equalJSON compares JSON-values, ordered maps are equal to maps with the same keys in the same order */
+func equalJSON(got, want any) bool {
+	switch want := want.(type) {
+	case types.OrderedMap:
+		got, ok := got.(types.OrderedMap)
+		if !ok || strings.Join(got.Keys, ",") != strings.Join(want.Keys, ",") {
+			return false
+		}
+		for key, value := range want.Values {
+			if !equalJSON(got.Values[key], value) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		got, ok := got.([]any)
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !equalJSON(got[i], want[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return got == want
+}
+
+func TestPathConcat(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "inside", src: `./dir + "/bin/tool"`, want: "/src/dir/bin/tool"},
+		{name: "cleaned", src: `./dir + "/bin/../lib"`, want: "/src/dir/lib"},
+		{name: "itself", src: `./dir + "/."`, want: "/src/dir"},
+		{name: "parent", src: `./dir + "/.."`, err: "outside of /src/dir"},
+		{name: "sibling", src: `./dir + "/../other"`, err: "outside of /src/dir"},
+		{name: "same prefix", src: `./dir + "2"`, err: "outside of /src/dir"},
+	})
+}
+
+/* object returns an ordered map of alternating keys and values */
+func object(pairs ...any) types.OrderedMap {
+	obj := types.OrderedMap{Values: make(map[string]any)}
+	for i := 0; i < len(pairs); i += 2 {
+		key := pairs[i].(string)
+		obj.Keys = append(obj.Keys, key)
+		obj.Values[key] = pairs[i+1]
+	}
+	return obj
+}
+
+func TestRecMap(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "sibling", src: `rec { "version": "1.2", "name": "foo-\(version)" }`,
+			want: object("version", "1.2", "name", "foo-1.2")},
+		{name: "chain", src: `rec { "c": "\(b)!", "b": "\(a)b", "a": "a" }`,
+			want: object("c", "ab!", "b", "ab", "a", "a")},
+		{name: "inherit", src: `let version = "2" in rec { "version": version, "name": "foo-\(version)" }`,
+			want: object("version", "2", "name", "foo-2")},
+		{name: "shadows outer", src: `let a = "outer" in rec { "a": "inner", "b": a }`,
+			want: object("a", "inner", "b", "inner")},
+		{name: "self", src: `rec { "a": "\(a)" }`, err: "cyclic reference: a -> a"},
+		{name: "cycle", src: `rec { "a": "\(b)", "b": "\(c)", "c": "\(a)" }`, err: "cyclic reference: a -> b -> c -> a"},
+		{name: "cycle after valid keys", src: `rec { "x": "x", "a": "\(x)\(b)", "b": a }`, err: "cyclic reference: a -> b -> a"},
+		{name: "dynamic key", src: `let k = "a" in rec { k: "b" }`, err: "must be constant strings"},
+	})
+}
+
+func TestOrderedMap(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{name: "definition order", src: `{ "z": 1, "a": 2, "m": 3 }`,
+			want: object("z", 1.0, "a", 2.0, "m", 3.0)},
+		{name: "extends appends", src: `let base = { "y": 1, "b": 2 } in { "z": 0, with base }`,
+			want: object("z", 0.0, "y", 1.0, "b", 2.0)},
+		{name: "filterAttrs", src: `filterAttrs({ "z": 1, "a": 2, "m": 3 }, fn(name, value) contains("zm", name))`,
+			want: object("z", 1.0, "m", 3.0)},
+		{name: "intersectAttrs", src: `intersectAttrs({ "a": 0, "z": 0 }, { "z": 1, "b": 2, "a": 3 })`,
+			want: object("z", 1.0, "a", 3.0)},
+	})
+}
+
+func TestOrderedMapEncoding(t *testing.T) {
+	value, err := evalString(t, `{ "z": 1, "a": { "y": true, "b": null }, "m": [{ "d": "x", "c": "y" }] }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		json any
+		want string
+	}{
+		{"ordered", value.JSON(), `{"z":1,"a":{"y":true,"b":null},"m":[{"d":"x","c":"y"}]}`},
+		{"sorted", types.SortKeys(value.JSON()), `{"a":{"b":null,"y":true},"m":[{"c":"y","d":"x"}],"z":1}`},
+	}
+	for _, test := range tests {
+		got, err := json.Marshal(test.json)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	vars, err := types.EncodeVariable("v", value.(types.MapValue).Values["a"], "prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(vars, " "); got != "v_y=1 v_b=" {
+		t.Errorf("prefix: got %q", got)
+	}
+	vars, err = types.EncodeVariable("v", value, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v=" + tests[0].want; vars[0] != want {
+		t.Errorf("json: got %q, want %q", vars[0], want)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	const config = `let config = { "build": { "jobs": 8, "cc": null } } in `
+	runEvalTests(t, []evalTest{
+		{name: "present", src: config + `config.build.jobs or 4`, want: 8.0},
+		{name: "missing key", src: config + `config.build.flags or "-O2"`, want: "-O2"},
+		{name: "missing intermediate", src: config + `config.run.jobs or 4`, want: 4.0},
+		{name: "null value", src: config + `config.build.cc or "cc"`, want: "cc"},
+		{name: "null literal", src: `null or "x"`, want: "x"},
+		{name: "false is kept", src: `false or true`, want: false},
+		{name: "chained", src: config + `config.a or config.b or config.build.jobs`, want: 8.0},
+		{name: "chained to default", src: config + `config.a or config.b or 1`, want: 1.0},
+		{name: "fallback is lazy", src: `"set" or missing`, want: "set"},
+		{name: "undefined variable", src: `missing or "x"`, err: "missing"},
+		{name: "error in fallback", src: `null or missing`, err: "missing"},
+	})
+}
+
+func TestOptionalChaining(t *testing.T) {
+	const config = `let config = { "build": { "jobs": 8, "cc": null } } in `
+	runEvalTests(t, []evalTest{
+		{name: "present", src: config + `config.?build.?jobs`, want: 8.0},
+		{name: "missing key", src: config + `config.?build.?flags`, want: nil},
+		{name: "missing intermediate", src: config + `config.?run.?jobs`, want: nil},
+		{name: "null intermediate", src: config + `config.build.cc.?name`, want: nil},
+		{name: "with fallback", src: config + `config.?run.?jobs or 2`, want: 2.0},
+		{name: "mixed with plain select", src: config + `config.?run.jobs`, err: "has no attributes"},
+		{name: "plain select", src: config + `config.build.flags`, err: "flags"},
+	})
+}
+
+func TestOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.zon"), []byte(`{ "pkg": { "version": "1.0", "name": "pkg" } }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		src      string
+		attrpath string
+		value    string
+		want     any
+		err      string
+	}{
+		{name: "key", src: `{ "a": 1, "b": 2 }`, attrpath: "b", value: `3`,
+			want: object("a", 1.0, "b", 3.0)},
+		{name: "nested", src: `{ "pkg": { "version": "1.0" } }`, attrpath: "pkg.version", value: `"2.0"`,
+			want: object("pkg", object("version", "2.0"))},
+		{name: "new key", src: `{ "a": 1 }`, attrpath: "b", value: `2`,
+			want: object("a", 1.0, "b", 2.0)},
+		{name: "let body", src: `let v = "1.0" in { "version": v }`, attrpath: "version", value: `"2.0"`,
+			want: object("version", "2.0")},
+		{name: "extended map", src: `let base = { "a": 1 } in { with base, "b": 2 }`, attrpath: "a", value: `5`,
+			want: object("b", 2.0, "a", 5.0)},
+		{name: "selected attribute", src: `{ "pkg": { "v": 1 } }.pkg`, attrpath: "v", value: `2`,
+			want: object("v", 2.0)},
+		{name: "condition", src: `if true then { "a": 1 } else { "a": 2 }`, attrpath: "a", value: `3`,
+			want: object("a", 3.0)},
+		{name: "included file", src: `include ./lib.zon`, attrpath: "pkg.version", value: `"2.0"`,
+			want: object("pkg", object("version", "2.0", "name", "pkg"))},
+		{name: "not a map", src: `{ "a": "text" }`, attrpath: "a.b", value: `1`, err: "unable to override attribute b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ast, err := parser.ParseString(test.src, filepath.Join(dir, "test.zon"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := parser.ParseString(test.value, "<override>")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got types.Value
+			ast, err = types.Override(ast, strings.Split(test.attrpath, "."), value)
+			if err == nil {
+				got, err = evalExpr(t, ast)
+			}
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !equalJSON(got.JSON(), test.want) {
+				t.Errorf("got %#v, want %#v", got.JSON(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+/* fetchArgs accepts either an url or a map with `url` and optional `name`, `rev` and `hash` */
+func fetchArgs(call CallExpr, args []Value) (url, name, rev, hash string, err error) {
+	if err := expectArgs(call, args, 1, 1); err != nil {
+		return "", "", "", "", err
+	}
+	switch arg := args[0].(type) {
+	case StringValue:
+		url = arg.Content
+	case MapValue:
+		urlValue, err := getValue[StringValue]("fetch", arg, "url")
+		if err != nil {
+			return "", "", "", "", err
+		}
+		url = urlValue.Content
+		for key, dest := range map[string]*string{"name": &name, "rev": &rev, "hash": &hash} {
+			if _, ok := arg.Values[key]; ok {
+				value, err := getValue[StringValue]("fetch", arg, key)
+				if err != nil {
+					return "", "", "", "", err
+				}
+				*dest = value.Content
+			}
+		}
+	default:
+		return "", "", "", "", evalErrorf(arg.Location(), "unable to fetch %T", arg)
+	}
+	if name == "" {
+		name = path.Base(strings.TrimSuffix(url, ".git"))
+	}
+	return url, name, rev, hash, nil
+}
+
+/* addInput records a fetched input as output of this evaluation */
+func (ev *Evaluator) addInput(name string, hash string, outpath string, cached bool) {
+	info := OutputInfo{
+		Name:   name,
+		Hash:   hash,
+		Path:   outpath,
+		Cached: cached,
+	}
+	if algorithm, _, ok := strings.Cut(hash, ":"); ok {
+		info.Algorithm = algorithm
+	} else {
+		/* revision of a git-repository */
+		info.Algorithm = "git"
+	}
+	if !cached {
+		if err := ev.writeIndex(info); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to index %s: %v\n", path.Base(outpath), err)
+		}
+	} else if !ev.DryRun {
+		ev.touchIndex(path.Base(outpath))
+	}
+	ev.addOutput(info)
+}
+
+/* download fetches `url` into a temporary file in the store, returning its path and hash of `algorithm` */
+func (ev *Evaluator) download(url string, algorithm string) (string, string, error) {
+	hashlib, err := NewHash(algorithm)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := ev.httpGet(url)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	os.MkdirAll(ev.CacheDir, 0755)
+	file, err := os.CreateTemp(ev.CacheDir, ".fetch-")
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(io.MultiWriter(file, hashlib), resp.Body); err != nil {
+		os.Remove(file.Name())
+		return "", "", err
+	}
+	return file.Name(), FormatHash(algorithm, hashlib), nil
+}
+
+/* fetchURL downloads `url` as input `name` into the store, verifying `hash` if it is not empty,
+ * it is pinned in the lock file by `url` under the name `key` */
+func (ev *Evaluator) fetchURL(pos Position, key string, name string, url string, hash string) (PathExpr, error) {
+	if entry, ok := ev.Lock.get("url", key, url, ""); ok && hash == "" {
+		hash = entry.Hash
+	}
+	algorithm := ev.HashAlgorithm
+	if hash != "" {
+		var err error
+		if algorithm, _, err = SplitHash(hash); err != nil {
+			return PathExpr{}, evalErrorf(pos, "invalid hash of %s: %w", name, err)
+		}
+	}
+
+	if hash == "" {
+		if err := ev.checkImpure(pos, "fetching "+url+" without hash"); err != nil {
+			return PathExpr{}, err
+		}
+	}
+
+	storename := func(hash string) string {
+		_, digest, _ := strings.Cut(hash, ":")
+		return ev.storeNameHex(digest, name)
+	}
+
+	if hash != "" {
+		if err := ev.checkCollision(pos, storename(hash), hash); err != nil {
+			return PathExpr{}, err
+		}
+		outpath := path.Join(ev.CacheDir, storename(hash))
+		if _, err := os.Stat(outpath); err == nil && ev.reusable(storename(hash)) {
+			ev.Lock.set(LockEntry{Name: key, Type: "url", URL: url, Hash: hash})
+			ev.addInput(name, hash, outpath, true)
+			return PathExpr{Position: pos, Name: outpath, OutputName: name}, nil
+		}
+	}
+
+	tmppath, gothash, err := ev.download(url, algorithm)
+	if err != nil {
+		return PathExpr{}, evalErrorf(pos, "unable to fetch %s: %w", url, err)
+	}
+	if hash != "" && hash != gothash {
+		os.Remove(tmppath)
+		return PathExpr{}, evalErrorf(pos, "hash mismatch for %s, expected %s, got %s", url, hash, gothash)
+	}
+
+	if err := ev.checkCollision(pos, storename(gothash), gothash); err != nil {
+		os.Remove(tmppath)
+		return PathExpr{}, err
+	}
+	outpath := path.Join(ev.CacheDir, storename(gothash))
+	if err := os.Rename(tmppath, outpath); err != nil {
+		os.Remove(tmppath)
+		return PathExpr{}, err
+	}
+	ev.Lock.set(LockEntry{Name: key, Type: "url", URL: url, Hash: gothash})
+	ev.addInput(name, gothash, outpath, false)
+	return PathExpr{Position: pos, Name: outpath, OutputName: name}, nil
+}
+
+/* fetch("url") or fetch({ url: "...", name: "...", hash: "sha256:..." }) downloads a file into the store */
+func builtinFetch(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	url, name, _, hash, err := fetchArgs(call, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := ev.fetchURL(call.Position, name, name, url, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, []PathExpr{res}, nil
+}
+
+/* resolveRev resolves `ref` of the repository at `url` to a commit-id */
+func (ev *Evaluator) resolveRev(url string, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	out, err := ev.gitCommand(url, "ls-remote", url, ref).Output()
+	if err != nil {
+		return "", err
+	}
+	rev, _, ok := strings.Cut(string(out), "\t")
+	if !ok {
+		return "", fmt.Errorf("no such reference: %s", ref)
+	}
+	return rev, nil
+}
+
+/* checkout clones `url` at `rev` into `dest` without history */
+func (ev *Evaluator) checkout(url string, rev string, dest string) error {
+	tmpdir, err := os.MkdirTemp(path.Dir(dest), ".fetch-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	git := func(args ...string) error {
+		cmd := ev.gitCommand(url, append([]string{"-C", tmpdir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %w\n%s", args[0], err, out)
+		}
+		return nil
+	}
+	if err := git("init", "-q"); err != nil {
+		return err
+	}
+	if err := git("fetch", "-q", "--depth", "1", url, rev); err != nil {
+		/* server does not allow fetching commits by id, fetch everything */
+		if err := git("fetch", "-q", url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+			return err
+		}
+		if err := git("checkout", "-q", rev); err != nil {
+			return err
+		}
+	} else if err := git("checkout", "-q", "FETCH_HEAD"); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path.Join(tmpdir, ".git")); err != nil {
+		return err
+	}
+	return os.Rename(tmpdir, dest)
+}
+
+/* fetchGit checks out `ref` of repository `url` as input `name` into the store */
+func (ev *Evaluator) fetchGit(pos Position, name string, url string, ref string) (PathExpr, error) {
+	var rev string
+	if len(ref) == 40 {
+		/* `ref` is a commit-id already, it is not overridden by the lock */
+		rev = ref
+	} else if entry, ok := ev.Lock.get("git", name, url, ref); ok {
+		rev = entry.Rev
+	} else {
+		if err := ev.checkImpure(pos, "fetching "+url+" without revision"); err != nil {
+			return PathExpr{}, err
+		}
+		resolved, err := ev.resolveRev(url, ref)
+		if err != nil {
+			return PathExpr{}, evalErrorf(pos, "unable to resolve %s of %s: %w", ref, url, err)
+		}
+		rev = resolved
+	}
+
+	if err := ev.checkCollision(pos, ev.storeNameHex(rev, name), rev); err != nil {
+		return PathExpr{}, err
+	}
+	outpath := path.Join(ev.CacheDir, ev.storeNameHex(rev, name))
+	cached := true
+	if _, err := os.Stat(outpath); err != nil || !ev.reusable(path.Base(outpath)) {
+		os.MkdirAll(ev.CacheDir, 0755)
+		if err := ev.checkout(url, rev, outpath); err != nil {
+			return PathExpr{}, evalErrorf(pos, "unable to fetch %s: %w", url, err)
+		}
+		cached = false
+	}
+	ev.Lock.set(LockEntry{Name: name, Type: "git", URL: url, Ref: ref, Rev: rev})
+	ev.addInput(name, rev, outpath, cached)
+	return PathExpr{Position: pos, Name: outpath, OutputName: name}, nil
+}
+
+/* fetchGit("url") or fetchGit({ url: "...", name: "...", rev: "branch or commit" }) checks out a git repository into the store */
+func builtinFetchGit(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	url, name, ref, _, err := fetchArgs(call, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := ev.fetchGit(call.Position, name, url, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, []PathExpr{res}, nil
+}
+
+/* remoteURL resolves an url or a `registry:path` shorthand like `github:owner/repo@rev/file.zon` */
+func (ev *Evaluator) remoteURL(ref string) (string, bool) {
+	scheme, rest, ok := strings.Cut(ref, ":")
+	if !ok {
+		return "", false
+	}
+	if template, ok := ev.Registry[scheme]; ok {
+		return strings.ReplaceAll(template, "{path}", rest), true
+	}
+	switch scheme {
+	case "http", "https":
+		return ref, true
+	case "github":
+		owner, rest, _ := strings.Cut(rest, "/")
+		repo, file, _ := strings.Cut(rest, "/")
+		repo, rev, ok := strings.Cut(repo, "@")
+		if !ok {
+			rev = "HEAD"
+		}
+		return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", owner, repo, rev, file), true
+	}
+	return "", false
+}
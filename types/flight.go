@@ -0,0 +1,29 @@
+package types
+
+/* an output being realized, shared by every evaluation reaching it */
+type flight struct {
+	done chan struct{}
+	info OutputInfo
+	err  error
+}
+
+/* singleflight calls `realize` once per `storename` in this evaluation,
+ * concurrent and later calls wait for and return the result of the first */
+func (ev *Evaluator) singleflight(storename string, realize func() (OutputInfo, error)) (OutputInfo, error) {
+	ev.flightMu.Lock()
+	if ev.flights == nil {
+		ev.flights = make(map[string]*flight)
+	}
+	if fl, ok := ev.flights[storename]; ok {
+		ev.flightMu.Unlock()
+		<-fl.done
+		return fl.info, fl.err
+	}
+	fl := &flight{done: make(chan struct{})}
+	ev.flights[storename] = fl
+	ev.flightMu.Unlock()
+
+	fl.info, fl.err = realize()
+	close(fl.done)
+	return fl.info, fl.err
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* a generation is a numbered symlink `result-N-link` pointing to the output of a build,
+ * `result` itself points to the current generation */
+type Generation struct {
+	Number int
+	Link   string
+	Target string
+	Time   time.Time
+}
+
+func generationLink(resname string, number int) string {
+	return fmt.Sprintf("%s-%d-link", resname, number)
+}
+
+/* Generations lists the generations of `resname` ordered by number */
+func Generations(resname string) ([]Generation, error) {
+	dir, base := path.Split(resname)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var gens []Generation
+	for _, entry := range entries {
+		numstr, ok := strings.CutPrefix(entry.Name(), base+"-")
+		if !ok {
+			continue
+		}
+		numstr, ok = strings.CutSuffix(numstr, "-link")
+		if !ok {
+			continue
+		}
+		num, err := strconv.Atoi(numstr)
+		if err != nil {
+			continue
+		}
+		link := path.Join(dir, entry.Name())
+		target, err := os.Readlink(link)
+		if err != nil {
+			continue
+		}
+		stat, err := os.Lstat(link)
+		if err != nil {
+			continue
+		}
+		gens = append(gens, Generation{Number: num, Link: link, Target: target, Time: stat.ModTime()})
+	}
+	slices.SortFunc(gens, func(a, b Generation) int {
+		return a.Number - b.Number
+	})
+	return gens, nil
+}
+
+/* CurrentGeneration returns the generation `resname` points to, or -1 if it does not point to a generation */
+func CurrentGeneration(resname string) int {
+	target, err := os.Readlink(resname)
+	if err != nil {
+		return -1
+	}
+	numstr, ok := strings.CutPrefix(target, path.Base(resname)+"-")
+	if !ok {
+		return -1
+	}
+	num, err := strconv.Atoi(strings.TrimSuffix(numstr, "-link"))
+	if err != nil {
+		return -1
+	}
+	return num
+}
+
+/* SwitchGeneration re-points `resname` to generation `number`, which must still exist in the store */
+func SwitchGeneration(resname string, number int) error {
+	if _, err := os.Stat(generationLink(resname, number)); err != nil {
+		return fmt.Errorf("generation %d is removed from the store: %w", number, err)
+	}
+	if stat, err := os.Lstat(resname); err == nil && (stat.Mode()&os.ModeType) != os.ModeSymlink {
+		return fmt.Errorf("unable to make symlink: exist")
+	}
+	os.Remove(resname)
+	return os.Symlink(path.Base(generationLink(resname, number)), resname)
+}
+
+/* addGeneration records `target` as new generation of `resname`, unless it is the latest generation already */
+func addGeneration(resname string, target string) error {
+	gens, err := Generations(resname)
+	if err != nil {
+		return err
+	}
+	number := 1
+	if len(gens) > 0 {
+		last := gens[len(gens)-1]
+		if last.Target == target {
+			return SwitchGeneration(resname, last.Number)
+		}
+		number = last.Number + 1
+	}
+	if err := os.Symlink(target, generationLink(resname, number)); err != nil {
+		return err
+	}
+	if err := registerRoot(generationLink(resname, number), target); err != nil {
+		return err
+	}
+	return SwitchGeneration(resname, number)
+}
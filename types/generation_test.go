@@ -0,0 +1,26 @@
+package types_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/friedelschoen/zon/types"
+)
+
+func TestSwitchGeneration(t *testing.T) {
+	dir := t.TempDir()
+	resname := filepath.Join(dir, "result")
+	os.Symlink(filepath.Join(dir, "removed"), resname+"-1-link")
+	os.Symlink(dir, resname+"-2-link")
+
+	if err := types.SwitchGeneration(resname, 1); err == nil {
+		t.Errorf("switched to generation of removed store-entry")
+	}
+	if err := types.SwitchGeneration(resname, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := types.CurrentGeneration(resname); got != 2 {
+		t.Errorf("current generation is %d, want 2", got)
+	}
+}
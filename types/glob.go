@@ -0,0 +1,84 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+/* GlobExpr is a path containing `*`, `?` or `**`, which expands to an array of the matching paths */
+type GlobExpr struct {
+	Position
+
+	Pattern string /* absolute */
+}
+
+/* expandGlob returns the paths below `base` matching `segments`, `**` matches any number of directories,
+ * hidden files only match segments starting with a dot */
+func expandGlob(base string, segments []string) []string {
+	if len(segments) == 0 {
+		return []string{base}
+	}
+	segment, rest := segments[0], segments[1:]
+	if !strings.ContainsAny(segment, "*?") {
+		if _, err := os.Lstat(path.Join(base, segment)); err != nil {
+			return nil
+		}
+		return expandGlob(path.Join(base, segment), rest)
+	}
+
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	if segment == "**" {
+		matches = expandGlob(base, rest)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") && !strings.HasPrefix(segment, ".") {
+			continue
+		}
+		if segment == "**" {
+			if entry.IsDir() {
+				matches = append(matches, expandGlob(path.Join(base, entry.Name()), segments)...)
+			}
+		} else if ok, _ := path.Match(segment, entry.Name()); ok {
+			matches = append(matches, expandGlob(path.Join(base, entry.Name()), rest)...)
+		}
+	}
+	return matches
+}
+
+/* paths returns the paths matching the pattern, sorted */
+func (obj GlobExpr) paths() []PathExpr {
+	names := expandGlob("/", strings.Split(strings.TrimPrefix(obj.Pattern, "/"), "/"))
+	slices.Sort(names)
+	names = slices.Compact(names)
+	paths := make([]PathExpr, len(names))
+	for i, name := range names {
+		paths[i] = PathExpr{Position: obj.Position, Name: name}
+	}
+	return paths
+}
+
+func (obj GlobExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	paths := obj.paths()
+	values := make([]Value, len(paths))
+	for i, p := range paths {
+		values[i] = p
+	}
+	return ArrayValue{obj.Position, values}, nil, nil
+}
+
+/* the matching files are hashed, so adding or changing one changes the hash */
+func (obj GlobExpr) hashValue(w io.Writer) {
+	fmt.Fprint(w, "glob")
+	fmt.Fprint(w, obj.Pattern)
+	for _, p := range obj.paths() {
+		p.hashValue(w)
+	}
+}
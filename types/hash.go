@@ -0,0 +1,79 @@
+package types
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/zeebo/blake3"
+)
+
+/* DefaultHash is the algorithm used if none is selected */
+const DefaultHash = "sha256"
+
+/* algorithms of hashes of inputs and store-entries, written as `<algorithm>:<hex>` */
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"blake3": func() hash.Hash { return blake3.New() },
+}
+
+/* NewHash returns a hash of `algorithm`, DefaultHash if empty */
+func NewHash(algorithm string) (hash.Hash, error) {
+	if algorithm == "" {
+		algorithm = DefaultHash
+	}
+	newHash, ok := hashAlgorithms[algorithm]
+	if !ok {
+		algorithms := make([]string, 0, len(hashAlgorithms))
+		for name := range hashAlgorithms {
+			algorithms = append(algorithms, name)
+		}
+		slices.Sort(algorithms)
+		return nil, fmt.Errorf("unknown hash-algorithm %s, expected one of %s", algorithm, strings.Join(algorithms, ", "))
+	}
+	return newHash(), nil
+}
+
+/* FormatHash returns the sum of `hashlib` as `<algorithm>:<hex>` */
+func FormatHash(algorithm string, hashlib hash.Hash) string {
+	if algorithm == "" {
+		algorithm = DefaultHash
+	}
+	return algorithm + ":" + hex.EncodeToString(hashlib.Sum(nil))
+}
+
+/* SplitHash splits `sum` into its algorithm and hex-digest, verifying the length of the digest */
+func SplitHash(sum string) (algorithm string, digest string, err error) {
+	algorithm, digest, ok := strings.Cut(sum, ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid hash %s, expected <algorithm>:<hex>", sum)
+	}
+	hashlib, err := NewHash(algorithm)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != 2*hashlib.Size() {
+		return "", "", fmt.Errorf("invalid %s-hash %s", algorithm, sum)
+	}
+	return algorithm, digest, nil
+}
+
+/* noteLegacy tells if the output stored as `storename` was stored with a FNV-key before,
+ * such entries are not reused as FNV is easily collided, they are rebuilt and removed by clean.
+ * Older versions hashed only the attributes, not the dependencies or the environment */
+func (ev *Evaluator) noteLegacy(obj OutputExpr, name string, storename string) {
+	legacy := fnv.New128()
+	obj.Attrs.hashValue(legacy)
+	legacyname := fmt.Sprintf("%x-%s", legacy.Sum(nil), name)
+	if _, err := os.Stat(path.Join(ev.CacheDir, legacyname)); err == nil {
+		fmt.Fprintf(os.Stderr, "%s has a legacy FNV-key, rebuilding as %s\n", legacyname, storename)
+	}
+}
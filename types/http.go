@@ -0,0 +1,171 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+)
+
+/* HTTPConfig configures the requests of fetchers and substituters */
+type HTTPConfig struct {
+	Proxy string                /* proxy of every request, HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used if empty */
+	Netrc string                /* file with credentials of hosts, ~/.netrc if empty */
+	Hosts map[string]HostConfig /* credentials and headers by host */
+
+	once   sync.Once
+	client *http.Client
+	err    error
+}
+
+/* settings of requests to a single host */
+type HostConfig struct {
+	Token   string            `toml:"token"`   /* sent as bearer-token */
+	Headers map[string]string `toml:"headers"` /* additional headers */
+}
+
+/* netrcCredentials returns login and password of `host` in the netrc-file `filename` */
+func netrcCredentials(filename string, host string) (login, password string, ok bool) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", "", false
+	}
+	var (
+		fields  = strings.Fields(string(content))
+		current string
+		found   bool
+	)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if found {
+				return login, password, true
+			}
+			if i+1 < len(fields) {
+				i++
+				current = fields[i]
+				found = current == host
+			}
+		case "default":
+			if found {
+				return login, password, true
+			}
+			current = ""
+			found = true
+		case "login", "password":
+			if i+1 >= len(fields) {
+				break
+			}
+			if found {
+				if fields[i] == "login" {
+					login = fields[i+1]
+				} else {
+					password = fields[i+1]
+				}
+			}
+			i++
+		}
+	}
+	return login, password, found
+}
+
+/* clientOf returns the http-client of `cfg`, created at first use */
+func (cfg *HTTPConfig) clientOf() (*http.Client, error) {
+	cfg.once.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if cfg.Proxy != "" {
+			proxy, err := url.Parse(cfg.Proxy)
+			if err != nil {
+				cfg.err = fmt.Errorf("invalid proxy: %w", err)
+				return
+			}
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+		cfg.client = &http.Client{Transport: transport}
+	})
+	return cfg.client, cfg.err
+}
+
+/* netrcFile returns the netrc-file to read credentials from */
+func (cfg *HTTPConfig) netrcFile() string {
+	if cfg.Netrc != "" {
+		return cfg.Netrc
+	}
+	if netrc := os.Getenv("NETRC"); netrc != "" {
+		return netrc
+	}
+	home, _ := os.UserHomeDir()
+	return path.Join(home, ".netrc")
+}
+
+/* headers returns the headers sent to `host`, a configured token precedes credentials in netrc */
+func (cfg *HTTPConfig) headers(host string) http.Header {
+	header := make(http.Header)
+	hostcfg, ok := cfg.Hosts[host]
+	if ok {
+		for key, value := range hostcfg.Headers {
+			header.Set(key, value)
+		}
+	}
+	if ok && hostcfg.Token != "" {
+		header.Set("Authorization", "Bearer "+hostcfg.Token)
+	} else if login, password, ok := netrcCredentials(cfg.netrcFile(), host); ok {
+		req := http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(login, password)
+		header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+	return header
+}
+
+/* httpDo requests `rawurl` with the proxy, credentials and headers of its host */
+func (ev *Evaluator) httpDo(method string, rawurl string, body io.Reader, header http.Header) (*http.Response, error) {
+	client, err := ev.HTTP.clientOf()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, rawurl, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range ev.HTTP.headers(req.URL.Hostname()) {
+		req.Header[key] = values
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return client.Do(req)
+}
+
+/* httpGet requests `rawurl` with the proxy, credentials and headers of its host */
+func (ev *Evaluator) httpGet(rawurl string) (*http.Response, error) {
+	return ev.httpDo(http.MethodGet, rawurl, nil, nil)
+}
+
+/* HTTPPost posts `body` of `contentType` to `rawurl` with the proxy, credentials and headers of its host */
+func (ev *Evaluator) HTTPPost(rawurl string, contentType string, body io.Reader) (*http.Response, error) {
+	return ev.httpDo(http.MethodPost, rawurl, body, http.Header{"Content-Type": {contentType}})
+}
+
+/* gitCommand returns git with `args` accessing `rawurl` with the proxy and headers of its host,
+ * credentials in netrc are read by git itself */
+func (ev *Evaluator) gitCommand(rawurl string, args ...string) *exec.Cmd {
+	var config []string
+	if u, err := url.Parse(rawurl); err == nil && u.Hostname() != "" {
+		hostcfg := ev.HTTP.Hosts[u.Hostname()]
+		for key, value := range hostcfg.Headers {
+			config = append(config, "-c", "http.extraHeader="+key+": "+value)
+		}
+		if hostcfg.Token != "" {
+			config = append(config, "-c", "http.extraHeader=Authorization: Bearer "+hostcfg.Token)
+		}
+	}
+	if ev.HTTP.Proxy != "" {
+		config = append(config, "-c", "http.proxy="+ev.HTTP.Proxy)
+	}
+	return exec.Command("git", append(config, args...)...)
+}
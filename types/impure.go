@@ -0,0 +1,89 @@
+package types
+
+import (
+	"maps"
+	"os"
+	"path"
+	"strings"
+)
+
+/* ImpureDir is the directory in the store holding impure outputs, only the latest output of every name is kept */
+const ImpureDir = "impure"
+
+/* builtins returning a different value every call, outputs using them are impure */
+var impureBuiltins = map[string]bool{
+	"currentTime":  true,
+	"randomString": true,
+	"uuid":         true,
+}
+
+/* pruneImpure removes the impure outputs named `name` which were not built in this evaluation */
+func (ev *Evaluator) pruneImpure(name string, outdir string) {
+	ev.impureMu.Lock()
+	defer ev.impureMu.Unlock()
+	if ev.impureBuilt == nil {
+		ev.impureBuilt = make(map[string]bool)
+	}
+	ev.impureBuilt[outdir] = true
+
+	dir := path.Join(ev.CacheDir, ImpureDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		entrypath := path.Join(dir, entry.Name())
+		if _, entryname, _ := strings.Cut(entry.Name(), "-"); entryname == name && !ev.impureBuilt[entrypath] {
+			os.RemoveAll(entrypath)
+			RemoveIndex(ev.CacheDir, entry.Name())
+		}
+	}
+}
+
+/* callsImpure reports whether `expr` in `scope` calls an impure builtin, following variables to their definitions,
+ * nested outputs are not entered as their paths change already */
+func callsImpure(expr Expression, scope Scope, seen map[string]bool) bool {
+	switch expr := expr.(type) {
+	case VarExpr:
+		if v, ok := scope[expr.Name]; !ok {
+			if impureBuiltins[expr.Name] {
+				return true
+			}
+		} else if v.Expr != nil {
+			key := v.Expr.Pos() + "\x00" + expr.Name
+			if !seen[key] {
+				seen[key] = true
+				if callsImpure(v.Expr, v.Scope, seen) {
+					return true
+				}
+			}
+		}
+	case DefineExpr:
+		newscope := maps.Clone(scope)
+		for name, def := range expr.Define {
+			newscope[name] = Variable{def, scope}
+		}
+		/* definitions are resolved in the outer scope */
+		for _, def := range expr.Define {
+			if callsImpure(def, scope, seen) {
+				return true
+			}
+		}
+		return callsImpure(expr.Expr, newscope, seen)
+	case LambdaExpr:
+		/* arguments are checked where the lambda is called */
+		newscope := maps.Clone(scope)
+		for _, name := range expr.Args {
+			newscope[name] = Variable{}
+		}
+		scope = newscope
+	case OutputExpr:
+		return false
+	}
+	for _, child := range children(expr) {
+		if callsImpure(child, scope, seen) {
+			return true
+		}
+	}
+	return false
+}
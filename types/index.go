@@ -0,0 +1,150 @@
+package types
+
+import (
+	"cmp"
+	"encoding/json"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+/* IndexDir is the directory in the store holding an index-entry for every store-entry */
+const IndexDir = ".index"
+
+/* IndexEntry describes how a store-entry was created */
+type IndexEntry struct {
+	Hash  string    `json:"hash"`
+	Name  string    `json:"name"`
+	Path  string    `json:"path"`
+	Built time.Time `json:"built"`
+	Used  time.Time `json:"used"` /* last time the entry was built or reused */
+	Root  string    `json:"root"` /* file which was evaluated */
+
+	MainProgram string `json:"mainProgram,omitempty"` /* executable relative to the store-entry */
+	Algorithm   string `json:"algorithm,omitempty"`   /* algorithm of the hash, fnv128 for entries of older versions if empty */
+	Content     string `json:"content,omitempty"`     /* hash of the content as `algorithm:hex`, checked by --verify */
+	Usage       *Usage `json:"usage,omitempty"`       /* resources used by the builder, nil for fetched and imported entries */
+
+	Depends []string `json:"depends"` /* store-names of the build-time dependencies */
+
+	Source string   `json:"source,omitempty"` /* absolute file:line:column of the output-expression in the last evaluation */
+	Attrs  []string `json:"attrs,omitempty"`  /* attribute path in the result of the last evaluation leading to the entry */
+	Via    []string `json:"via,omitempty"`    /* store-names of the dependents between the attribute and the entry */
+}
+
+/* StoreName returns the name of the entry in the store */
+func (entry IndexEntry) StoreName() string {
+	return path.Base(entry.Path)
+}
+
+/* writeIndex records `info` in the index of the store */
+func (ev *Evaluator) writeIndex(info OutputInfo) error {
+	entry := IndexEntry{
+		Hash:  info.Hash,
+		Name:  info.Name,
+		Path:  info.Path,
+		Built: time.Now(),
+		Used:  time.Now(),
+		Root:  ev.RootFile,
+
+		MainProgram: info.MainProgram,
+		Algorithm:   info.Algorithm,
+	}
+	for _, dep := range info.Depends {
+		entry.Depends = append(entry.Depends, path.Base(dep.Name))
+	}
+	contentHash, err := ContentHash(info.Path, cmp.Or(ev.HashAlgorithm, DefaultHash))
+	if err != nil {
+		return err
+	}
+	entry.Content = contentHash
+	if info.Usage.Wall > 0 {
+		entry.Usage = &info.Usage
+	}
+	content, err := json.MarshalIndent(entry, "", "\t")
+	if err != nil {
+		return err
+	}
+	dir := path.Join(ev.CacheDir, IndexDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(dir, entry.StoreName()+".json"), content, 0644)
+}
+
+/* ReadIndex returns the index of the store at `cachedir`, keyed by the name of the store-entry */
+func ReadIndex(cachedir string) (map[string]IndexEntry, error) {
+	index := make(map[string]IndexEntry)
+	dir := path.Join(cachedir, IndexDir)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var entry IndexEntry
+		if err := json.Unmarshal(content, &entry); err != nil {
+			return nil, err
+		}
+		index[entry.StoreName()] = entry
+	}
+	return index, nil
+}
+
+/* RemoveIndex removes the index-entry of store-entry `storename` */
+func RemoveIndex(cachedir string, storename string) error {
+	err := os.Remove(path.Join(cachedir, IndexDir, storename+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+/* LookupIndex returns the entries with name `query` or a hash starting with `query`, latest first */
+func LookupIndex(cachedir string, query string) ([]IndexEntry, error) {
+	index, err := ReadIndex(cachedir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []IndexEntry
+	for _, entry := range index {
+		if entry.Name == query || strings.HasPrefix(entry.Hash, query) || entry.StoreName() == query {
+			matches = append(matches, entry)
+		}
+	}
+	slices.SortFunc(matches, func(a, b IndexEntry) int {
+		return cmp.Compare(b.Built.UnixNano(), a.Built.UnixNano())
+	})
+	return matches, nil
+}
+
+/* updateIndex applies `update` to the index-entry of store-entry `storename`, entries of older versions without index are skipped */
+func (ev *Evaluator) updateIndex(storename string, update func(entry *IndexEntry)) {
+	filename := path.Join(ev.CacheDir, IndexDir, storename+".json")
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	var entry IndexEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return
+	}
+	update(&entry)
+	if content, err = json.MarshalIndent(entry, "", "\t"); err == nil {
+		os.WriteFile(filename, content, 0644)
+	}
+}
+
+/* touchIndex records that store-entry `storename` was reused */
+func (ev *Evaluator) touchIndex(storename string) {
+	ev.updateIndex(storename, func(entry *IndexEntry) {
+		entry.Used = time.Now()
+	})
+}
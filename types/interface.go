@@ -3,21 +3,157 @@ package types
 import (
 	"fmt"
 	"io"
-	"path"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
+/* Evaluator holds the configuration and state of an evaluation, it may resolve expressions from multiple goroutines at once:
+ * configuration is only read, Outputs, Stats and Warnings are only written under a lock and are complete when Resolve returned */
 type Evaluator struct {
-	Force        bool
-	DryRun       bool
-	CacheDir     string
-	LogDir       string
-	Serial       bool
-	Interpreter  string
-	NoEvalOutput bool
+	Force          bool
+	DryRun         bool
+	CacheDir       string
+	LogDir         string
+	Serial         bool
+	Interpreter    string
+	NoEvalOutput   bool
+	Strict         bool              /* reject unknown output-attributes, implicit coercions and impure constructs */
+	AllowImpure    bool              /* allow impure constructs in strict mode */
+	LogTailLines   int               /* lines of the log included in a build-error, none if 0 */
+	CompressLogs   int64             /* size in bytes above which logs of successful builds are gzipped, never if 0 */
+	Strip          []string          /* patterns of files removed from every output */
+	Sandbox        bool              /* build in a fixed environment instead of inheriting it */
+	Check          bool              /* rebuild cached outputs and compare them to the stored ones */
+	Verify         bool              /* check the content of cached store-entries before reusing them */
+	KeepGoing      bool              /* let other builders finish when one failed instead of killing them */
+	LegacyEnv      bool              /* pass every attribute of an output to the builder, not only `env` */
+	Secrets        Secrets           /* values of the `secrets` of outputs */
+	Redact         []string          /* regular expressions masked in the logs of every output */
+	Jobs           int               /* maximum of concurrent builds, unlimited if 0 */
+	Substituters   []string          /* binary caches to query for missing outputs */
+	Registry       map[string]string /* url-templates of `name:path` includes, {path} is replaced */
+	HashAlgorithm  string            /* algorithm of hashes of outputs and fetched inputs, DefaultHash if empty */
+	HTTP           HTTPConfig        /* proxy, credentials and headers of fetchers and substituters */
+	BuildUser      string            /* user or uid:gid builders run as if zon runs as root, DefaultBuildUser if empty */
+	StoreKeyLength int               /* characters of the hash in store-names, DefaultStoreKeyLength if 0 */
 
+	RootFile  string /* file which is evaluated */
 	ParseFile func(filename PathExpr) (Expression, error)
+	Lock      *Lock                 /* pinned external inputs, not pinned if nil */
+	Profile   *Profile              /* records time spent resolving expressions, if not nil */
+	Trace     *Trace                /* writes every resolved expression, if not nil */
+	OnFailure func(err *BuildError) /* called when a builder failed, from the goroutine of the build */
 
-	Outputs []string
+	Outputs  []OutputInfo
+	Stats    Stats
+	Warnings []Warning
+
+	outputsMu sync.Mutex
+	warnMu    sync.Mutex
+
+	impureMu    sync.Mutex
+	impureBuilt map[string]bool
+
+	buildUserOnce sync.Once
+
+	flightMu sync.Mutex
+	flights  map[string]*flight
+
+	buildersMu sync.Mutex
+	builders   map[*exec.Cmd]bool /* running builders, killed by cancelBuilds */
+	cancelled  bool
+
+	jobsOnce sync.Once
+	jobs     chan struct{}
+
+	progress progress
+}
+
+/* Resolve resolves `expr` in `scope`, every expression is resolved through here */
+func (ev *Evaluator) Resolve(expr Expression, scope Scope) (Value, []PathExpr, error) {
+	if ev.Profile == nil && ev.Trace == nil {
+		return expr.Resolve(scope, ev)
+	}
+	if ev.Trace != nil {
+		ev.Trace.enter()
+	}
+	start := time.Now()
+	val, deps, err := expr.Resolve(scope, ev)
+	if ev.Profile != nil {
+		ev.Profile.record(expr, time.Since(start))
+	}
+	if ev.Trace != nil {
+		ev.Trace.leave(expr, val, err, time.Since(start))
+	}
+	return val, deps, err
+}
+
+/* addOutput records `info` in Outputs */
+func (ev *Evaluator) addOutput(info OutputInfo) {
+	ev.outputsMu.Lock()
+	defer ev.outputsMu.Unlock()
+	ev.Outputs = append(ev.Outputs, info)
+}
+
+/* acquire blocks until a build-slot is available and returns a function releasing it */
+func (ev *Evaluator) acquire() func() {
+	if ev.Jobs <= 0 {
+		return func() {}
+	}
+	ev.jobsOnce.Do(func() {
+		ev.jobs = make(chan struct{}, ev.Jobs)
+	})
+	ev.jobs <- struct{}{}
+	return func() {
+		<-ev.jobs
+	}
+}
+
+/* metadata of an output evaluated in this run */
+type OutputInfo struct {
+	Name     string
+	Hash     string
+	Path     string
+	Duration time.Duration
+	Cached   bool
+	Depends  []PathExpr
+
+	Substituted bool   /* fetched from a substituter instead of built */
+	Algorithm   string /* algorithm of Hash */
+
+	MainProgram string /* executable relative to the output, if `mainProgram` is set */
+
+	Phases []Phase /* phases of the build marked by the builder */
+	Usage  Usage   /* resources used by the builder, zero if not built */
+}
+
+func (info OutputInfo) JSON() any {
+	depends := make([]any, len(info.Depends))
+	for i, dep := range info.Depends {
+		depends[i] = dep.Name
+	}
+	phases := make([]any, len(info.Phases))
+	for i, phase := range info.Phases {
+		phases[i] = map[string]any{
+			"name":     phase.Name,
+			"duration": phase.Duration.Seconds(),
+		}
+	}
+	return map[string]any{
+		"name":        info.Name,
+		"hash":        info.Hash,
+		"path":        info.Path,
+		"duration":    info.Duration.Seconds(),
+		"cached":      info.Cached,
+		"substituted": info.Substituted,
+		"depends":     depends,
+		"phases":      phases,
+		"usage":       info.Usage.JSON(),
+	}
 }
 
 type Variable struct {
@@ -30,6 +166,7 @@ type Scope map[string]Variable
 /* unresolved value */
 type Expression interface {
 	Pos() string
+	Location() Position
 	hashValue(w io.Writer)
 	Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error)
 }
@@ -37,6 +174,7 @@ type Expression interface {
 /* resolved value */
 type Value interface {
 	Pos() string
+	Location() Position
 	encodeEnviron(root bool) (string, error)
 	Link(resultname string) error
 	JSON() any
@@ -53,10 +191,39 @@ func (obj Position) String() string {
 	return obj.Pos()
 }
 
+func (obj Position) Location() Position {
+	return obj
+}
+
+/* working directory filenames in positions are relative to */
+var workDir, _ = os.Getwd()
+
+/* Path returns the filename relative to the working directory if it is inside of it */
+func (obj Position) Path() string {
+	if !filepath.IsAbs(obj.Filename) || workDir == "" {
+		return obj.Filename
+	}
+	rel, err := filepath.Rel(workDir, obj.Filename)
+	if err != nil || strings.HasPrefix(rel, "../") {
+		return obj.Filename
+	}
+	return rel
+}
+
+/* Column returns the 1-based column */
+func (obj Position) Column() int {
+	return obj.Offset + 1
+}
+
+/* Pos formats the position as file:line:column like compilers do, so editors can jump to it */
 func (obj Position) Pos() string {
 	if obj.Filename == "" {
 		return "<unknown>"
 	}
+	if obj.Line == 0 {
+		/* position of a whole file */
+		return obj.Path()
+	}
 
-	return fmt.Sprintf("%s:%d:%d", path.Base(obj.Filename), obj.Line, obj.Offset)
+	return fmt.Sprintf("%s:%d:%d", obj.Path(), obj.Line, obj.Column())
 }
@@ -0,0 +1,248 @@
+package types
+
+import (
+	"cmp"
+	"slices"
+)
+
+/* compareValues orders strings, numbers, booleans and paths, values of different types are not comparable */
+func compareValues(a, b Value) (int, error) {
+	switch a := a.(type) {
+	case StringValue:
+		if b, ok := b.(StringValue); ok {
+			return cmp.Compare(a.Content, b.Content), nil
+		}
+	case NumberExpr:
+		if b, ok := b.(NumberExpr); ok {
+			return cmp.Compare(a.Value, b.Value), nil
+		}
+	case BooleanExpr:
+		if b, ok := b.(BooleanExpr); ok {
+			switch {
+			case a.Value == b.Value:
+				return 0, nil
+			case b.Value:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case PathExpr:
+		if b, ok := b.(PathExpr); ok {
+			return cmp.Compare(a.Name, b.Name), nil
+		}
+	}
+	return 0, evalErrorf(b.Location(), "unable to compare %T with %T", a, b)
+}
+
+/* compare(a, b) returns -1, 0 or 1 if `a` is ordered before, equal to or after `b`, as used by sort */
+func builtinCompare(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	order, err := compareValues(args[0], args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return NumberExpr{call.Position, float64(order)}, nil, nil
+}
+
+/* sort(array) or sort(array, fn(a, b) ...) sorts an array stable, the comparator returns a number like
+ * compare(a, b) or whether `a` is ordered before `b` */
+func builtinSort(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 1, 2); err != nil {
+		return nil, nil, err
+	}
+	array, err := argValue[ArrayValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var deps []PathExpr
+	compare := compareValues
+	if len(args) > 1 {
+		callComparator := func(a, b Value) (Value, error) {
+			res, paths, err := callFunction(call, args[1], []Value{a, b}, scope, ev)
+			deps = append(deps, paths...)
+			return res, err
+		}
+		compare = func(a, b Value) (int, error) {
+			res, err := callComparator(a, b)
+			if err != nil {
+				return 0, err
+			}
+			switch res := res.(type) {
+			case NumberExpr:
+				return cmp.Compare(res.Value, 0), nil
+			case BooleanExpr:
+				if res.Value {
+					return -1, nil
+				}
+				/* `a` is not ordered before `b`, they are equal unless `b` is ordered before `a` */
+				reverse, err := callComparator(b, a)
+				if err != nil {
+					return 0, err
+				}
+				if before, ok := reverse.(BooleanExpr); ok && before.Value {
+					return 1, nil
+				}
+				return 0, nil
+			}
+			return 0, evalErrorf(res.Location(), "comparator should return a number or boolean, got %T", res)
+		}
+	}
+
+	values := slices.Clone(array.Values)
+	var sortErr error
+	slices.SortStableFunc(values, func(a, b Value) int {
+		if sortErr != nil {
+			return 0
+		}
+		order, err := compare(a, b)
+		if err != nil {
+			sortErr = err
+		}
+		return order
+	})
+	if sortErr != nil {
+		return nil, nil, sortErr
+	}
+	return ArrayValue{call.Position, values}, deps, nil
+}
+
+/* unique(array) removes repeated strings, numbers, booleans and paths from an array, keeping the first */
+func builtinUnique(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 1, 1); err != nil {
+		return nil, nil, err
+	}
+	array, err := argValue[ArrayValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		seen   = make(map[any]bool)
+		values []Value
+	)
+	for _, value := range array.Values {
+		var key any
+		switch value := value.(type) {
+		case StringValue:
+			key = value.Content
+		case NumberExpr:
+			key = value.Value
+		case BooleanExpr:
+			key = value.Value
+		case PathExpr:
+			/* a path is not equal to a string of the same name */
+			type pathKey string
+			key = pathKey(value.Name)
+		default:
+			return nil, nil, evalErrorf(value.Location(), "unable to compare %T", value)
+		}
+		if !seen[key] {
+			seen[key] = true
+			values = append(values, value)
+		}
+	}
+	return ArrayValue{call.Position, values}, nil, nil
+}
+
+/* zip(a, b) pairs the elements of two arrays of the same length as [a, b], or combines them with
+ * zip(a, b, fn(x, y) ...) */
+func builtinZip(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 3); err != nil {
+		return nil, nil, err
+	}
+	left, err := argValue[ArrayValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := argValue[ArrayValue](call, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(left.Values) != len(right.Values) {
+		return nil, nil, evalErrorf(call.Position, "unable to zip arrays of different length: %d and %d", len(left.Values), len(right.Values))
+	}
+	var deps []PathExpr
+	values := make([]Value, len(left.Values))
+	for i := range values {
+		if len(args) > 2 {
+			res, paths, err := callFunction(call, args[2], []Value{left.Values[i], right.Values[i]}, scope, ev)
+			if err != nil {
+				return nil, nil, err
+			}
+			values[i] = res
+			deps = append(deps, paths...)
+		} else {
+			values[i] = ArrayValue{call.Position, []Value{left.Values[i], right.Values[i]}}
+		}
+	}
+	return ArrayValue{call.Position, values}, deps, nil
+}
+
+/* unzip(pairs) splits an array of pairs into an array of the first and an array of the second elements */
+func builtinUnzip(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 1, 1); err != nil {
+		return nil, nil, err
+	}
+	pairs, err := argValue[ArrayValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	first := make([]Value, len(pairs.Values))
+	second := make([]Value, len(pairs.Values))
+	for i, elem := range pairs.Values {
+		pair, ok := elem.(ArrayValue)
+		if !ok || len(pair.Values) != 2 {
+			return nil, nil, evalErrorf(elem.Location(), "expected a pair of two elements, got %T", elem)
+		}
+		first[i], second[i] = pair.Values[0], pair.Values[1]
+	}
+	return ArrayValue{call.Position, []Value{ArrayValue{call.Position, first}, ArrayValue{call.Position, second}}}, nil, nil
+}
+
+/* flattenValues appends the elements of `values` to `res`, arrays are flattened `depth` levels, all if negative */
+func flattenValues(res []Value, values []Value, depth int) []Value {
+	for _, value := range values {
+		if array, ok := value.(ArrayValue); ok && depth != 0 {
+			res = flattenValues(res, array.Values, depth-1)
+		} else {
+			res = append(res, value)
+		}
+	}
+	return res
+}
+
+/* flatten(array) or flatten(array, depth) merges nested arrays into one, one level by default and
+ * every level if depth is -1 */
+func builtinFlatten(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 1, 2); err != nil {
+		return nil, nil, err
+	}
+	array, err := argValue[ArrayValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	depth := 1
+	if len(args) > 1 {
+		if depth, err = argInt(call, args, 1); err != nil {
+			return nil, nil, err
+		}
+	}
+	return ArrayValue{call.Position, flattenValues(nil, array.Values, depth)}, nil, nil
+}
+
+/* elem(value, array) returns whether `array` contains a string, number, boolean or path equal to `value` */
+func builtinElem(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	array, err := argValue[ArrayValue](call, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := slices.ContainsFunc(array.Values, func(value Value) bool {
+		return equalValues(args[0], value)
+	})
+	return BooleanExpr{call.Position, found}, nil, nil
+}
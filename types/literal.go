@@ -23,7 +23,7 @@ func (obj StringValue) encodeEnviron(root bool) (string, error) {
 }
 
 func (obj StringValue) Link(string) error {
-	return fmt.Errorf("%s: unable to symlink object of type: %T", obj.Pos(), obj)
+	return evalErrorf(obj.Position, "unable to symlink object of type: %T", obj)
 }
 
 func (obj StringValue) Boolean() (bool, error) {
@@ -49,7 +49,7 @@ func (obj StringExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, er
 		if obj.Interp[i] == nil {
 			continue
 		}
-		intp, paths, err := obj.Interp[i].Resolve(scope, ev)
+		intp, paths, err := ev.Resolve(obj.Interp[i], scope)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -60,7 +60,7 @@ func (obj StringExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, er
 		case PathExpr:
 			res.WriteString(intp.Name)
 		default:
-			return nil, nil, fmt.Errorf("%s: unable to interpolate %T", obj.Pos(), intp)
+			return nil, nil, evalErrorf(obj.Position, "unable to interpolate %T", intp)
 		}
 	}
 	return StringValue{
@@ -107,7 +107,7 @@ func (obj NumberExpr) encodeEnviron(root bool) (string, error) {
 }
 
 func (obj NumberExpr) Link(string) error {
-	return fmt.Errorf("%s: unable to symlink object of type: %T", obj.Pos(), obj)
+	return evalErrorf(obj.Position, "unable to symlink object of type: %T", obj)
 }
 
 func (obj NumberExpr) Boolean() (bool, error) {
@@ -145,14 +145,43 @@ func (obj BooleanExpr) encodeEnviron(root bool) (string, error) {
 }
 
 func (obj BooleanExpr) Link(string) error {
-	return fmt.Errorf("%s: unable to symlink object of type: %T", obj.Pos(), obj)
+	return evalErrorf(obj.Position, "unable to symlink object of type: %T", obj)
+}
+
+type NullExpr struct {
+	Position
+}
+
+func (obj NullExpr) JSON() any {
+	return nil
+}
+
+func (obj NullExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	return obj, nil, nil
+}
+
+func (obj NullExpr) Boolean() (bool, error) {
+	return false, nil
+}
+
+func (obj NullExpr) hashValue(w io.Writer) {
+	fmt.Fprintf(w, "null")
+}
+
+func (obj NullExpr) encodeEnviron(root bool) (string, error) {
+	return "", nil
+}
+
+func (obj NullExpr) Link(string) error {
+	return evalErrorf(obj.Position, "unable to symlink object of type: %T", obj)
 }
 
 type PathExpr struct {
 	Position
 
-	Name    string
-	Depends []PathExpr
+	Name       string
+	OutputName string /* `name` of the output which produced this path */
+	Depends    []PathExpr
 }
 
 func (obj PathExpr) JSON() any {
@@ -187,11 +216,7 @@ func (obj PathExpr) encodeEnviron(root bool) (string, error) {
 
 func (obj PathExpr) Link(resname string) error {
 	if resname != "" {
-		if stat, err := os.Lstat(resname); err == nil && (stat.Mode()&os.ModeType) != os.ModeSymlink {
-			return fmt.Errorf("unable to make symlink: exist")
-		}
-		os.Remove(resname)
-		return os.Symlink(obj.Name, resname)
+		return addGeneration(resname, obj.Name)
 	}
 	return nil
 }
@@ -0,0 +1,108 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+)
+
+/* resolved state of an external input */
+type LockEntry struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	Ref  string `json:"ref,omitempty"` /* branch or tag `Rev` is resolved from */
+	Rev  string `json:"rev,omitempty"`
+	Hash string `json:"hash,omitempty"`
+}
+
+/* Lock pins external inputs to the revision and hash they were first fetched with */
+type Lock struct {
+	Inputs map[string]LockEntry `json:"inputs"` /* keyed by url and ref, inputs of the same name may come from different urls */
+
+	Filename  string   `json:"-"`
+	Update    []string `json:"-"` /* inputs to refresh, ignoring their entry */
+	UpdateAll bool     `json:"-"`
+
+	mu      sync.Mutex
+	changed bool
+}
+
+/* ReadLock reads `filename`, an absent lock file results in an empty lock */
+func ReadLock(filename string) (*Lock, error) {
+	lock := &Lock{
+		Filename: filename,
+		Inputs:   make(map[string]LockEntry),
+	}
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, lock); err != nil {
+		return nil, err
+	}
+	/* older lock files are keyed by name */
+	for key, entry := range lock.Inputs {
+		if key != lockKey(entry.URL, entry.Ref) {
+			delete(lock.Inputs, key)
+			if entry.Name == "" {
+				entry.Name = key
+			}
+			lock.Inputs[lockKey(entry.URL, entry.Ref)] = entry
+			lock.changed = true
+		}
+	}
+	return lock, nil
+}
+
+/* lockKey returns the key of the entry of `url` at `ref`, the same repository may be fetched at different refs */
+func lockKey(url string, ref string) string {
+	if ref == "" {
+		return url
+	}
+	return url + "#" + ref
+}
+
+/* get returns the entry of type `typ` locked to `url` at `ref` if input `name` is not to be updated */
+func (lock *Lock) get(typ string, name string, url string, ref string) (LockEntry, bool) {
+	if lock == nil || lock.UpdateAll || slices.Contains(lock.Update, name) || slices.Contains(lock.Update, url) {
+		return LockEntry{}, false
+	}
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+	entry, ok := lock.Inputs[lockKey(url, ref)]
+	if !ok || entry.Type != typ || entry.Ref != ref {
+		return LockEntry{}, false
+	}
+	return entry, true
+}
+
+func (lock *Lock) set(entry LockEntry) {
+	if lock == nil {
+		return
+	}
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+	if key := lockKey(entry.URL, entry.Ref); lock.Inputs[key] != entry {
+		lock.Inputs[key] = entry
+		lock.changed = true
+	}
+}
+
+/* Write writes the lock file if any entry has changed */
+func (lock *Lock) Write() error {
+	if lock == nil || !lock.changed {
+		return nil
+	}
+	content, err := json.MarshalIndent(lock, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lock.Filename, append(content, '\n'), 0644)
+}
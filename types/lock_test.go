@@ -0,0 +1,95 @@
+package types_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+)
+
+/* gitRepo creates a repository with a commit of file `version` for every element of `versions`,
+ * branch `old` points to the first commit, it returns the url and the commit-ids */
+func gitRepo(t *testing.T, versions ...string) (string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.org"}, args...)...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Skipf("git is not available: %v", err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q", "-b", "main")
+	var commits []string
+	for _, version := range versions {
+		if err := os.WriteFile(filepath.Join(dir, "version"), []byte(version), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "version")
+		git("commit", "-q", "-m", version)
+		commits = append(commits, git("rev-parse", "HEAD"))
+	}
+	git("branch", "old", commits[0])
+	return "file://" + dir, commits
+}
+
+func TestLockGit(t *testing.T) {
+	url, commits := gitRepo(t, "1", "2")
+	tests := []struct {
+		name    string
+		inputs  map[string]types.LockEntry
+		src     string
+		want    []string /* content of version of every fetched repository */
+		entries int      /* entries of the lock afterwards */
+	}{
+		{"locked ref", map[string]types.LockEntry{
+			url + "#main": {Name: "repo", Type: "git", URL: url, Ref: "main", Rev: commits[0]},
+		}, `fetchGit({ "url": "` + url + `", "rev": "main" })`, []string{"1"}, 1},
+		{"explicit commit", map[string]types.LockEntry{
+			url + "#main": {Name: "repo", Type: "git", URL: url, Ref: "main", Rev: commits[0]},
+		}, `fetchGit({ "url": "` + url + `", "rev": "` + commits[1] + `" })`, []string{"2"}, 2},
+		{"other ref", map[string]types.LockEntry{
+			url + "#main": {Name: "repo", Type: "git", URL: url, Ref: "main", Rev: commits[1]},
+		}, `fetchGit({ "url": "` + url + `", "rev": "old" })`, []string{"1"}, 2},
+		{"different refs", map[string]types.LockEntry{},
+			`[fetchGit({ "url": "` + url + `", "rev": "main" }), fetchGit({ "url": "` + url + `", "rev": "old" })]`, []string{"2", "1"}, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ast, err := parser.ParseString(test.src, filepath.Join(t.TempDir(), "test.zon"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lock := &types.Lock{Filename: filepath.Join(t.TempDir(), "zon.lock"), Inputs: test.inputs}
+			ev := &types.Evaluator{
+				ParseFile: parser.ParseFile,
+				CacheDir:  t.TempDir(),
+				LogDir:    t.TempDir(),
+				DryRun:    true,
+				Lock:      lock,
+			}
+			value, _, err := ev.Resolve(ast, make(types.Scope))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			paths := []types.Value{value}
+			if array, ok := value.(types.ArrayValue); ok {
+				paths = array.Values
+			}
+			for i, path := range paths {
+				content, err := os.ReadFile(filepath.Join(path.(types.PathExpr).Name, "version"))
+				if err != nil || string(content) != test.want[i] {
+					t.Errorf("got version %q, error %v, want %q", content, err, test.want[i])
+				}
+			}
+			if len(lock.Inputs) != test.entries {
+				t.Errorf("got lock entries %v, want %d", lock.Inputs, test.entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+/* CompressLog gzips `filename` to `filename`.gz and removes the original */
+func CompressLog(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(filename + ".gz")
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+	return os.Remove(filename)
+}
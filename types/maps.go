@@ -0,0 +1,51 @@
+package types
+
+/* filterAttrs(map, fn(name, value) ...) keeps the entries of a map for which the predicate is true */
+func builtinFilterAttrs(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	attrs, err := argValue[MapValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var deps []PathExpr
+	res := MapValue{Position: call.Position, Values: make(map[string]Value)}
+	for _, name := range attrs.Ordered() {
+		keepValue, paths, err := callFunction(call, args[1], []Value{StringValue{call.Position, name}, attrs.Values[name]}, scope, ev)
+		if err != nil {
+			return nil, nil, err
+		}
+		deps = append(deps, paths...)
+		keep, err := keepValue.Boolean()
+		if err != nil {
+			return nil, nil, err
+		}
+		if keep {
+			res.set(name, attrs.Values[name])
+		}
+	}
+	return res, deps, nil
+}
+
+/* intersectAttrs(a, b) returns the entries of `b` whose names are in `a` */
+func builtinIntersectAttrs(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	left, err := argValue[MapValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := argValue[MapValue](call, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := MapValue{Position: call.Position, Values: make(map[string]Value)}
+	for _, name := range right.Ordered() {
+		if _, ok := left.Values[name]; ok {
+			res.set(name, right.Values[name])
+		}
+	}
+	return res, nil, nil
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+/* modification time of every file in the store, equal to the one used by other stores */
+var storeEpoch = time.Unix(1, 0)
+
+/* PATH of sandboxed builds */
+const sandboxPath = "/usr/local/bin:/usr/bin:/bin"
+
+/* sandboxEnviron returns the environment of sandboxed builds, which does not depend on the machine or user */
+func sandboxEnviron() []string {
+	return []string{
+		"PATH=" + sandboxPath,
+		"SOURCE_DATE_EPOCH=" + strconv.FormatInt(storeEpoch.Unix(), 10),
+		"TZ=UTC",
+		"LC_ALL=C",
+	}
+}
+
+/* normalize makes the content of the output at `outdir` independent of the time of the build
+ * and strips world-writable permissions, files with a name matching one of `strip` are removed */
+func normalize(outdir string, strip []string) error {
+	var names []string
+	err := filepath.WalkDir(outdir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != outdir && slices.ContainsFunc(strip, func(pattern string) bool {
+			match, _ := path.Match(pattern, entry.Name())
+			return match
+		}) {
+			if err := os.RemoveAll(name); err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	/* children first, so changing them does not update the time of their directory afterwards */
+	for _, name := range slices.Backward(names) {
+		info, err := os.Lstat(name)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			continue
+		}
+		if perm := info.Mode().Perm(); perm&0002 != 0 {
+			if err := os.Chmod(name, perm&^0002); err != nil {
+				return err
+			}
+		}
+		if name == outdir {
+			/* the time of the store-entry itself tells when it was built, used by clean */
+			continue
+		}
+		if err := os.Chtimes(name, storeEpoch, storeEpoch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
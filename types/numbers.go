@@ -0,0 +1,74 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/* toFixed(n, digits) formats a number with `digits` decimals */
+func builtinToFixed(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	num, err := argValue[NumberExpr](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	digits, err := argInt(call, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if digits < 0 {
+		return nil, nil, evalErrorf(args[1].Location(), "negative number of decimals: %d", digits)
+	}
+	return StringValue{call.Position, strconv.FormatFloat(num.Value, 'f', digits, 64)}, nil, nil
+}
+
+/* toHex(n) formats a whole number in lowercase hexadecimal */
+func builtinToHex(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 1, 1); err != nil {
+		return nil, nil, err
+	}
+	num, err := argInt(call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return StringValue{call.Position, strconv.FormatInt(int64(num), 16)}, nil, nil
+}
+
+/* padStart(value, width) or padStart(value, width, fill) prepends `fill`, a space by default, to a string or number
+ * until it is `width` characters long */
+func builtinPadStart(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 3); err != nil {
+		return nil, nil, err
+	}
+	var str string
+	switch value := args[0].(type) {
+	case StringValue:
+		str = value.Content
+	case NumberExpr:
+		str, _ = value.encodeEnviron(false)
+	default:
+		return nil, nil, evalErrorf(value.Location(), "argument 1 should be a string or number, got %T", value)
+	}
+	width, err := argInt(call, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	fill := " "
+	if len(args) > 2 {
+		fillValue, err := argValue[StringValue](call, args, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		if utf8.RuneCountInString(fillValue.Content) != 1 {
+			return nil, nil, evalErrorf(fillValue.Position, "fill should be a single character, got %q", fillValue.Content)
+		}
+		fill = fillValue.Content
+	}
+	if missing := width - utf8.RuneCountInString(str); missing > 0 {
+		str = strings.Repeat(fill, missing) + str
+	}
+	return StringValue{call.Position, str}, nil, nil
+}
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+/* OrderedMap is the JSON of a map, encoded with the keys in the order they were defined */
+type OrderedMap struct {
+	Keys   []string
+	Values map[string]any
+}
+
+func (obj OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range obj.Keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		enc, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(enc)
+		buf.WriteByte(':')
+		if enc, err = json.Marshal(obj.Values[key]); err != nil {
+			return nil, err
+		}
+		buf.Write(enc)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+/* SortKeys returns `value` with every OrderedMap replaced by a plain map, which is encoded with sorted keys */
+func SortKeys(value any) any {
+	switch value := value.(type) {
+	case OrderedMap:
+		res := make(map[string]any, len(value.Values))
+		for key, elem := range value.Values {
+			res[key] = SortKeys(elem)
+		}
+		return res
+	case map[string]any:
+		res := make(map[string]any, len(value))
+		for key, elem := range value {
+			res[key] = SortKeys(elem)
+		}
+		return res
+	case []any:
+		res := make([]any, len(value))
+		for i, elem := range value {
+			res[i] = SortKeys(elem)
+		}
+		return res
+	}
+	return value
+}
+
+/* Ordered returns the keys of `obj` in the order they were defined, sorted if the order is unknown */
+func (obj MapValue) Ordered() []string {
+	if len(obj.Keys) == len(obj.Values) {
+		return obj.Keys
+	}
+	return slices.Sorted(maps.Keys(obj.Values))
+}
+
+/* set sets `key` to `value`, new keys are appended to the order */
+func (obj *MapValue) set(key string, value Value) {
+	if obj.Values == nil {
+		obj.Values = make(map[string]Value)
+	}
+	if _, ok := obj.Values[key]; !ok {
+		obj.Keys = append(obj.Keys, key)
+	}
+	obj.Values[key] = value
+}
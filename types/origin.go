@@ -0,0 +1,91 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+/* origin of an output in the result */
+type origin struct {
+	pos   Position
+	attrs []string
+	via   []string
+}
+
+/* RecordOrigins records in the index of every output reachable from `result` the position of its output-expression
+ * and the attribute path leading to it, dependencies not in the result are reached through the shortest chain of dependents */
+func (ev *Evaluator) RecordOrigins(result Value) {
+	outputs := make(map[string]bool)
+	for _, info := range ev.Outputs {
+		outputs[info.Path] = true
+	}
+	origins := make(map[string]origin)
+	var queue []PathExpr
+	/* visit records the origin of `p`, paths inside of an output are not part of the chain and lead to their outputs right away */
+	var visit func(p PathExpr, orig origin)
+	visit = func(p PathExpr, orig origin) {
+		if _, ok := origins[p.Name]; ok {
+			return
+		}
+		orig.pos = p.Position
+		origins[p.Name] = orig
+		if outputs[p.Name] {
+			queue = append(queue, p)
+			return
+		}
+		for _, dep := range p.Depends {
+			visit(dep, orig)
+		}
+	}
+	var walk func(val Value, attrs []string)
+	walk = func(val Value, attrs []string) {
+		switch val := val.(type) {
+		case MapValue:
+			for _, key := range val.Ordered() {
+				walk(val.Values[key], append(attrs[:len(attrs):len(attrs)], key))
+			}
+		case ArrayValue:
+			for i, elem := range val.Values {
+				walk(elem, append(attrs[:len(attrs):len(attrs)], strconv.Itoa(i)))
+			}
+		case PathExpr:
+			visit(val, origin{attrs: attrs})
+		}
+	}
+	walk(result, nil)
+
+	/* breadth-first, so every dependency gets the shortest chain */
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		parent := origins[p.Name]
+		via := append(parent.via[:len(parent.via):len(parent.via)], path.Base(p.Name))
+		for _, dep := range p.Depends {
+			visit(dep, origin{attrs: parent.attrs, via: via})
+		}
+	}
+
+	for name, orig := range origins {
+		if !outputs[name] {
+			continue
+		}
+		ev.updateIndex(path.Base(name), func(entry *IndexEntry) {
+			entry.Source = absolutePos(orig.pos)
+			entry.Attrs = orig.attrs
+			entry.Via = orig.via
+		})
+	}
+}
+
+/* absolutePos formats `pos` like Pos, with the absolute filename so it stays valid in other directories */
+func absolutePos(pos Position) string {
+	if abs, err := filepath.Abs(pos.Filename); err == nil && pos.Filename != "" {
+		pos.Filename = abs
+	}
+	if pos.Line == 0 {
+		return pos.Filename
+	}
+	return fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column())
+}
@@ -1,24 +1,29 @@
 package types
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
-	"hash/fnv"
+	"hash"
 	"io"
 	"math/rand"
 	"os"
 	"os/exec"
 	"path"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
 func getValue[T Value](resultname string, result MapValue, name string) (ret T, err error) {
 	valueAny, ok := result.Values[name]
 	if !ok {
-		return ret, fmt.Errorf("%s: %s has no attribute '%s'", result.Pos(), resultname, name)
+		return ret, evalErrorf(result.Position, "%s has no attribute '%s'", resultname, name)
 	}
 	value, ok := valueAny.(T)
 	if !ok {
-		return ret, fmt.Errorf("%s: %s attribute '%s' should be a %T, got %T", result.Pos(), resultname, name, ret, valueAny)
+		return ret, evalErrorf(result.Position, "%s attribute '%s' should be a %T, got %T", resultname, name, ret, valueAny)
 	}
 	return value, nil
 }
@@ -34,10 +39,15 @@ func (obj OutputExpr) hashValue(w io.Writer) {
 	obj.Attrs.hashValue(w)
 }
 
-func (obj OutputExpr) build(result MapValue, outdir string, hashstr string, ev *Evaluator) error {
-	start := time.Now()
+/* what was recorded while building an output */
+type buildReport struct {
+	phases []Phase
+	usage  Usage
+}
 
+func (obj OutputExpr) build(result MapValue, outdir string, hashstr string, ev *Evaluator) (buildReport, error) {
 	os.RemoveAll(outdir)
+	os.MkdirAll(path.Dir(outdir), 0755)
 	success := false
 	defer func() {
 		if !success {
@@ -52,14 +62,14 @@ func (obj OutputExpr) build(result MapValue, outdir string, hashstr string, ev *
 		token = outputAny
 		install, err := getValue[StringValue]("output", result, "output")
 		if err != nil {
-			return err
+			return buildReport{}, err
 		}
 
 		exec := ev.Interpreter
 		if _, ok := result.Values["interpreter"]; ok {
 			execValue, err := getValue[StringValue]("output", result, "interpreter")
 			if err != nil {
-				return err
+				return buildReport{}, err
 			}
 			exec = execValue.Content
 		}
@@ -68,92 +78,295 @@ func (obj OutputExpr) build(result MapValue, outdir string, hashstr string, ev *
 		token = builderAny
 		builder, err := getValue[StringValue]("output", result, "builder")
 		if err != nil {
-			return err
+			return buildReport{}, err
 		}
 		cmdline = []string{builder.Content}
 	} else {
-		return fmt.Errorf("%s: missing output or builder", obj.Pos())
+		return buildReport{}, evalErrorf(obj.Position, "missing output or builder")
 	}
 
 	if _, ok := result.Values["args"]; ok {
 		args, err := getValue[ArrayValue]("output", result, "args")
 		if err != nil {
-			return err
+			return buildReport{}, err
 		}
 		for _, elem := range args.Values[1:] {
 			arg, ok := elem.(StringValue)
 			if !ok {
-				return fmt.Errorf("%s: non-string in args: %T", elem.Pos(), elem)
+				return buildReport{}, evalErrorf(elem.Location(), "non-string in args: %T", elem)
 			}
 			cmdline = append(cmdline, string(arg.Content))
 		}
 	}
 
+	builduser, err := ev.buildUser()
+	if err != nil {
+		return buildReport{}, evalErrorf(obj.Position, "invalid build-user: %w", err)
+	}
+
+	/* the store is not writable to the builder, it creates $out in a staging directory which is moved into place afterwards */
+	buildout := outdir
+	var staging string
+	if ev.Sandbox || builduser != nil {
+		staging, err = os.MkdirTemp(ev.CacheDir, ".build-")
+		if err != nil {
+			return buildReport{}, evalErrorf(obj.Position, "unable to create staging directory: %w", err)
+		}
+		defer os.RemoveAll(staging)
+		buildout = path.Join(staging, hashstr)
+	}
+
+	substitutePlaceholder(cmdline, buildout)
+
 	var builddir string
 	var deletebuilddir bool
 
-	if _, ok := result.Values["source"]; ok {
+	inPlace := false
+	if inPlaceAny, ok := result.Values["sourceInPlace"].(BooleanExpr); ok {
+		inPlace = inPlaceAny.Value
+	}
+
+	defer func() {
+		if deletebuilddir {
+			os.RemoveAll(builddir)
+		}
+	}()
+
+	if _, ok := result.Values["source"]; ok && inPlace {
 		sourcedir, err := getValue[PathExpr]("output", result, "source")
 		if err != nil {
-			return err
+			return buildReport{}, err
 		}
 		builddir = sourcedir.Name
 	} else {
 		var err error
 		builddir, err = os.MkdirTemp("", "zon-")
 		if err != nil {
-			return err
+			return buildReport{}, evalErrorf(obj.Position, "unable to create build directory: %w", err)
 		}
 		deletebuilddir = true
+
+		if ok {
+			/* the builder works on a copy, so the original source is not touched */
+			sourcedir, err := getValue[PathExpr]("output", result, "source")
+			if err != nil {
+				return buildReport{}, err
+			}
+			if err := copyTree(sourcedir.Name, builddir); err != nil {
+				return buildReport{}, evalErrorf(sourcedir.Position, "unable to copy source: %w", err)
+			}
+		}
 	}
 
-	defer func() {
-		if deletebuilddir {
-			os.RemoveAll(builddir)
+	encoding := "plain"
+	if _, ok := result.Values["encoding"]; ok {
+		encValue, err := getValue[StringValue]("output", result, "encoding")
+		if err != nil {
+			return buildReport{}, err
 		}
-	}()
+		encoding = encValue.Content
+	}
 
-	environ := append(os.Environ(), "out="+outdir)
-	for key, value := range result.Values {
-		enc, err := value.encodeEnviron(true)
+	var environ []string
+	if ev.Sandbox {
+		/* only the build directory is writable, temporary files go there */
+		environ = append(sandboxEnviron(), "TMPDIR="+builddir)
+	} else {
+		environ = os.Environ()
+	}
+	environ = append(environ, "out="+buildout)
+	for key, value := range ev.outputEnviron(result) {
+		if ev.Strict && key != "impure" {
+			if err := checkCoercion(value, encoding); err != nil {
+				return buildReport{}, err
+			}
+		}
+		vars, err := EncodeVariable(key, value, encoding)
 		if err != nil {
-			return err
+			return buildReport{}, err
+		}
+		substitutePlaceholder(vars, buildout)
+		environ = append(environ, vars...)
+	}
+
+	var secrets []string
+	if secretsAny, ok := result.Values["secrets"].(MapValue); ok {
+		for key, nameAny := range secretsAny.Values {
+			name := nameAny.(StringValue)
+			value, err := ev.Secrets.get(name.Content)
+			if err != nil {
+				return buildReport{}, evalErrorf(name.Position, "unable to read secret: %w", err)
+			}
+			environ = append(environ, key+"="+value)
+			secrets = append(secrets, value)
 		}
-		environ = append(environ, key+"="+enc)
 	}
+	slices.Sort(environ)
 
 	logpath := path.Join(ev.LogDir, hashstr+".log")
+	/* the compressed log of a previous build would be shadowed */
+	os.Remove(logpath + ".gz")
 	logfile, err := os.Create(logpath)
 	if err != nil {
 		logfile = os.Stdout
+	} else {
+		defer func() {
+			logfile.Close()
+			if !success {
+				/* logs of failed builds are read right away, keep them uncompressed */
+				return
+			}
+			if info, err := os.Stat(logpath); err == nil && ev.CompressLogs > 0 && info.Size() > ev.CompressLogs {
+				if err := CompressLog(logpath); err != nil {
+					fmt.Fprintf(os.Stderr, "unable to compress log of %s: %v\n", hashstr, err)
+				}
+			}
+		}()
+	}
+
+	stamp := &stampWriter{w: logfile, start: time.Now()}
+	var out io.Writer = stamp
+	tail := &tailWriter{max: ev.LogTailLines}
+	if ev.LogTailLines > 0 {
+		out = io.MultiWriter(out, tail)
+	}
+	redact := &redactWriter{w: out, secrets: secrets}
+	patterns := slices.Clone(ev.Redact)
+	if redactAny, ok := result.Values["redact"].(ArrayValue); ok {
+		for _, elem := range redactAny.Values {
+			patterns = append(patterns, elem.(StringValue).Content)
+		}
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return buildReport{}, evalErrorf(obj.Position, "invalid redact-pattern: %w", err)
+		}
+		redact.patterns = append(redact.patterns, re)
+	}
+	out = redact
+
+	if builduser != nil {
+		/* sources built in place stay owned by their user */
+		chowndir := builddir
+		if !deletebuilddir {
+			chowndir = ""
+		}
+		if err := builduser.prepare(chowndir, staging); err != nil {
+			return buildReport{}, evalErrorf(obj.Position, "unable to prepare build as unprivileged user: %w", err)
+		}
 	}
-	defer logfile.Close()
 
-	cmd := exec.Command(cmdline[0], cmdline[1:]...)
+	var cmd *exec.Cmd
+	var monitor *writeMonitor
+	if ev.Sandbox {
+		monitor, err = newWriteMonitor()
+		if err != nil {
+			return buildReport{}, evalErrorf(obj.Position, "unable to monitor builder: %w", err)
+		}
+		defer monitor.close()
+		cmd, err = sandboxedCommand(cmdline, writableDirs(staging, builddir), monitor, builduser)
+		if err != nil {
+			return buildReport{}, evalErrorf(obj.Position, "unable to sandbox builder: %w", err)
+		}
+	} else {
+		cmd = exec.Command(cmdline[0], cmdline[1:]...)
+		if builduser != nil {
+			builduser.apply(cmd)
+		}
+	}
 	cmd.Env = environ
 	cmd.Dir = builddir
-	cmd.Stdin = nil
-	cmd.Stdout = logfile
-	cmd.Stderr = logfile
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s: building %s failed, for logs look in %s: %w", token.Pos(), hashstr, logpath, err)
+	switch stdin := result.Values["stdin"].(type) {
+	case StringValue:
+		cmd.Stdin = strings.NewReader(stdin.Content)
+	case PathExpr:
+		file, err := os.Open(stdin.Name)
+		if err != nil {
+			return buildReport{}, evalErrorf(stdin.Position, "unable to open stdin: %w", err)
+		}
+		defer file.Close()
+		cmd.Stdin = file
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	runStart := time.Now()
+	err = ev.startBuilder(cmd)
+	if err == nil {
+		err = ev.waitBuilder(cmd)
+	}
+	if errors.Is(err, ErrCancelled) {
+		return buildReport{}, evalErrorf(obj.Position, "building %s %w", hashstr, err)
+	}
+	usage := processUsage(cmd.ProcessState, time.Since(runStart))
+	redact.Flush()
+	if monitor != nil {
+		illegal, monitorErr := monitor.illegal()
+		if monitorErr != nil {
+			err = fmt.Errorf("unable to collect writes of builder: %w", monitorErr)
+		} else if len(illegal) > 0 {
+			err = fmt.Errorf("illegal writes outside $out and the build directory:\n  %s", strings.Join(illegal, "\n  "))
+		}
+	}
+	if err != nil {
+		return buildReport{}, &BuildError{Position: token.Location(), Hash: hashstr, LogPath: logpath, Tail: tail.String(), Err: err}
 	}
 
-	dur := time.Since(start).Round(time.Millisecond)
-	fmt.Fprintf(os.Stderr, "%s (%v)\n", hashstr, dur)
+	if builduser != nil {
+		if err := chownTree(buildout, os.Geteuid(), os.Getegid()); err != nil {
+			return buildReport{}, evalErrorf(obj.Position, "unable to take ownership of %s: %w", hashstr, err)
+		}
+	}
+	if buildout != outdir {
+		if err := os.Rename(buildout, outdir); err != nil {
+			return buildReport{}, evalErrorf(obj.Position, "unable to move %s into the store: %w", hashstr, err)
+		}
+	}
+
+	strip := ev.Strip
+	if stripAny, ok := result.Values["strip"].(ArrayValue); ok {
+		for _, elem := range stripAny.Values {
+			strip = append(strip[:len(strip):len(strip)], elem.(StringValue).Content)
+		}
+	}
+	if err := normalize(outdir, strip); err != nil {
+		return buildReport{}, evalErrorf(obj.Position, "unable to normalize %s: %w", hashstr, err)
+	}
 
 	success = true
-	return nil
+	return buildReport{phases: stamp.Phases(), usage: usage}, nil
+}
+
+/* writeInputs writes everything the output depends on to `hashlib` */
+func (obj OutputExpr) writeInputs(hashlib hash.Hash, deps []PathExpr, ev *Evaluator) {
+	obj.Attrs.hashValue(hashlib)
+	if ev.LegacyEnv {
+		/* the builder gets a different environment */
+		fmt.Fprint(hashlib, "legacy-env")
+	}
+	/* store paths of dependencies contain their hash, so changed inputs result in a new hash */
+	for _, dep := range deps {
+		fmt.Fprint(hashlib, dep.Name)
+	}
 }
 
 func (obj OutputExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
-	attrsAny, deps, err := obj.Attrs.Resolve(scope, ev)
+	attrsAny, deps, err := ev.Resolve(obj.Attrs, scope)
 	if err != nil {
 		return nil, nil, err
 	}
 	result, ok := attrsAny.(MapValue)
 	if !ok {
-		return nil, nil, fmt.Errorf("%s: unable to output non-map: %T", obj.Pos(), attrsAny)
+		return nil, nil, evalErrorf(obj.Position, "unable to output non-map: %T", attrsAny)
+	}
+
+	if err := ev.validateOutput(obj, result); err != nil {
+		return nil, nil, err
+	}
+	if ev.Strict {
+		if err := checkStrict(result); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	impure := false
@@ -162,8 +375,21 @@ func (obj OutputExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, er
 			impure = impureVal.Value
 		}
 	}
+	if !impure && callsImpure(obj.Attrs, scope, make(map[string]bool)) {
+		/* the result changes with every build */
+		impure = true
+	}
+	if impure {
+		if err := ev.checkImpure(obj.Position, "output"); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	hashlib := fnv.New128()
+	algorithm := cmp.Or(ev.HashAlgorithm, DefaultHash)
+	hashlib, err := NewHash(algorithm)
+	if err != nil {
+		return nil, nil, evalErrorf(obj.Position, "%w", err)
+	}
 	var hashsum []byte
 	if impure {
 		hashsum = make([]byte, hashlib.Size())
@@ -171,7 +397,7 @@ func (obj OutputExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, er
 			hashsum[i] = byte(rand.Int())
 		}
 	} else {
-		obj.Attrs.hashValue(hashlib)
+		obj.writeInputs(hashlib, deps, ev)
 		hashsum = hashlib.Sum(nil)
 	}
 
@@ -180,20 +406,103 @@ func (obj OutputExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, er
 		return nil, nil, err
 	}
 
-	hashstr := fmt.Sprintf("%x-%s", hashsum, name.Content)
+	hashstr := ev.storeName(hashsum, name.Content)
+	if !impure {
+		if err := ev.checkCollision(obj.Position, hashstr, fmt.Sprintf("%x", hashsum)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	outdir := path.Join(ev.CacheDir, hashstr)
+	if impure {
+		outdir = path.Join(ev.CacheDir, ImpureDir, hashstr)
+	}
+
+	info := OutputInfo{
+		Name:    name.Content,
+		Hash:    fmt.Sprintf("%x", hashsum),
+		Path:    outdir,
+		Cached:  true,
+		Depends: deps,
+
+		Algorithm: algorithm,
+	}
+	if mainProgram, ok := result.Values["mainProgram"].(StringValue); ok {
+		info.MainProgram = mainProgram.Content
+	}
+
+	if impure {
+		info, err = obj.realize(result, info, impure, ev)
+	} else {
+		/* the same output may be reached through multiple branches, build it only once */
+		info, err = ev.singleflight(hashstr, func() (OutputInfo, error) {
+			return obj.realize(result, info, impure, ev)
+		})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-	ev.Outputs = append(ev.Outputs, hashstr)
+	ev.addOutput(info)
 
-	cwd, _ := os.Getwd()
-	outdir := path.Join(cwd, ev.CacheDir, hashstr)
+	res := PathExpr{Position: obj.Position, Name: outdir, OutputName: name.Content, Depends: deps}
+	return res, []PathExpr{res}, nil
+}
 
-	if _, err := os.Stat(outdir); !ev.DryRun && (err != nil || ev.Force) {
-		err = obj.build(result, outdir, hashstr, ev)
+/* realize substitutes or builds the output described by `info` if it is not cached */
+func (obj OutputExpr) realize(result MapValue, info OutputInfo, impure bool, ev *Evaluator) (OutputInfo, error) {
+	storename := path.Base(info.Path)
+	_, err := os.Stat(info.Path)
+	if err != nil || ev.Force || (!impure && !ev.reusable(storename)) {
+		info.Cached = false
+	}
+	if !info.Cached && !impure && !ev.Force {
+		ev.noteLegacy(obj, info.Name, storename)
+	}
+	if info.Cached && !ev.DryRun {
+		ev.touchIndex(storename)
+	}
+	if !ev.DryRun && !info.Cached && !impure && !ev.Force && len(ev.Substituters) > 0 {
+		/* substitutions share the build-slots, so --jobs limits them as well */
+		release := ev.acquire()
+		info.Substituted = ev.substitute(storename)
+		release()
+	}
+	if !ev.DryRun && !info.Cached && !info.Substituted {
+		release := ev.acquire()
+		done := ev.Stats.begin()
+		start := time.Now()
+		report, err := obj.build(result, info.Path, storename, ev)
+		done(err != nil)
+		release()
+		var buildErr *BuildError
+		if errors.As(err, &buildErr) {
+			ev.cancelBuilds()
+			if ev.OnFailure != nil {
+				ev.OnFailure(buildErr)
+			}
+		}
 		if err != nil {
-			return nil, nil, err
+			return info, err
+		}
+		info.Duration = time.Since(start)
+		info.Phases = report.phases
+		info.Usage = report.usage
+		if err := ev.writeIndex(info); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to index %s: %v\n", storename, err)
+		}
+		if impure {
+			ev.pruneImpure(info.Name, info.Path)
 		}
+		fmt.Fprintf(os.Stderr, "%s (%v)\n", storename, info.Duration.Round(time.Millisecond))
+	} else if !ev.DryRun && ev.Check && !impure {
+		release := ev.acquire()
+		err = obj.check(result, info.Path, storename, ev)
+		release()
+		if err != nil {
+			return info, err
+		}
+		fmt.Fprintf(os.Stderr, "%s (reproducible)\n", storename)
 	}
-
-	res := PathExpr{Name: outdir, Depends: deps}
-	return res, []PathExpr{res}, err
+	return info, nil
 }
@@ -0,0 +1,108 @@
+package types
+
+import (
+	"slices"
+	"strings"
+)
+
+type attrOverride struct {
+	path  []string
+	value Expression
+}
+
+/* ConstantKey returns the content of a map-key without interpolations */
+func ConstantKey(expr Expression) (string, bool) {
+	str, ok := expr.(StringExpr)
+	if !ok || len(str.Content) != 1 || str.Interp[0] != nil {
+		return "", false
+	}
+	return str.Content[0], true
+}
+
+/* Override replaces the attribute at `attrpath` inside `expr` by `value` before `expr` is resolved,
+ * it descends into maps, extended maps, let-bodies, attributes, outputs and includes */
+func Override(expr Expression, attrpath []string, value Expression) (Expression, error) {
+	if len(attrpath) == 0 {
+		return value, nil
+	}
+	switch obj := expr.(type) {
+	case MapExpr:
+		for i := 0; i < len(obj.Exprs); i += 2 {
+			if key, ok := ConstantKey(obj.Exprs[i]); ok && key == attrpath[0] {
+				newvalue, err := Override(obj.Exprs[i+1], attrpath[1:], value)
+				if err != nil {
+					return nil, err
+				}
+				obj.Exprs = slices.Clone(obj.Exprs)
+				obj.Exprs[i+1] = newvalue
+				return obj, nil
+			}
+		}
+		/* later extends take precedence */
+		for i := len(obj.Extends) - 1; i >= 0; i-- {
+			newext, err := Override(obj.Extends[i], attrpath, value)
+			if err != nil {
+				continue
+			}
+			obj.Extends = slices.Clone(obj.Extends)
+			obj.Extends[i] = newext
+			return obj, nil
+		}
+		if len(attrpath) == 1 && len(obj.Extends) > 0 {
+			/* keys of extended maps take precedence over explicit keys, so the attribute is extended last */
+			over := MapExpr{Position: obj.Position, Exprs: []Expression{StringConstant(attrpath[0], "<override>"), value}}
+			return MapExpr{Position: obj.Position, Extends: []Expression{obj, over}}, nil
+		}
+		if len(attrpath) == 1 {
+			obj.Exprs = append(slices.Clone(obj.Exprs), StringConstant(attrpath[0], "<override>"), value)
+			return obj, nil
+		}
+	case DefineExpr:
+		newexpr, err := Override(obj.Expr, attrpath, value)
+		if err != nil {
+			return nil, err
+		}
+		obj.Expr = newexpr
+		return obj, nil
+	case InputsExpr:
+		newexpr, err := Override(obj.Expr, attrpath, value)
+		if err != nil {
+			return nil, err
+		}
+		obj.Expr = newexpr
+		return obj, nil
+	case OutputExpr:
+		newattrs, err := Override(obj.Attrs, attrpath, value)
+		if err != nil {
+			return nil, err
+		}
+		obj.Attrs = newattrs
+		return obj, nil
+	case ConditionExpr:
+		truly, terr := Override(obj.Truly, attrpath, value)
+		falsy, ferr := Override(obj.Falsy, attrpath, value)
+		if terr != nil && ferr != nil {
+			return nil, terr
+		}
+		if terr == nil {
+			obj.Truly = truly
+		}
+		if ferr == nil {
+			obj.Falsy = falsy
+		}
+		return obj, nil
+	case AttributeExpr:
+		/* override the selected attribute of the base */
+		newbase, err := Override(obj.Base, append([]string{obj.Name}, attrpath...), value)
+		if err != nil {
+			return nil, err
+		}
+		obj.Base = newbase
+		return obj, nil
+	case IncludeExpr:
+		/* the included file is not parsed yet, override once it is */
+		obj.overrides = append(slices.Clone(obj.overrides), attrOverride{attrpath, value})
+		return obj, nil
+	}
+	return nil, evalErrorf(expr.Location(), "unable to override attribute %s in %T", strings.Join(attrpath, "."), expr)
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/* PhaseMarker starts a phase of a build if a builder prints it at the beginning of a line, like `@zon phase configure` */
+const PhaseMarker = "@zon phase "
+
+/* a named part of a build, started by a PhaseMarker */
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+/* stampWriter prefixes every line with the time since `start` and records phase-markers */
+type stampWriter struct {
+	w      io.Writer
+	start  time.Time
+	midway bool /* the last write did not end with a newline */
+	line   []byte
+
+	phases []Phase
+	since  time.Time /* start of the current phase */
+}
+
+func (s *stampWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, chunk := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(chunk) == 0 {
+			continue
+		}
+		if !s.midway {
+			fmt.Fprintf(&buf, "[%10.3f] ", time.Since(s.start).Seconds())
+		}
+		buf.Write(chunk)
+		s.line = append(s.line, chunk...)
+		s.midway = chunk[len(chunk)-1] != '\n'
+		if !s.midway {
+			s.mark(string(bytes.TrimSpace(s.line)))
+			s.line = s.line[:0]
+		}
+	}
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+/* mark starts a new phase if `line` is a phase-marker */
+func (s *stampWriter) mark(line string) {
+	name, ok := strings.CutPrefix(line, PhaseMarker)
+	if !ok {
+		return
+	}
+	s.finish()
+	s.phases = append(s.phases, Phase{Name: strings.TrimSpace(name)})
+	s.since = time.Now()
+}
+
+/* finish ends the current phase */
+func (s *stampWriter) finish() {
+	if len(s.phases) > 0 && s.phases[len(s.phases)-1].Duration == 0 {
+		s.phases[len(s.phases)-1].Duration = time.Since(s.since)
+	}
+}
+
+/* Phases ends the current phase and returns the recorded phases */
+func (s *stampWriter) Phases() []Phase {
+	s.finish()
+	return s.phases
+}
@@ -0,0 +1,21 @@
+package types
+
+import "strings"
+
+/* OutPlaceholder is replaced with the output-directory in the environment and arguments of a builder */
+const OutPlaceholder = "/zon-placeholder-out"
+
+/* placeholder() returns a token which is replaced with $out just before the builder runs */
+func builtinPlaceholder(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 0, 0); err != nil {
+		return nil, nil, err
+	}
+	return StringValue{call.Position, OutPlaceholder}, nil, nil
+}
+
+/* substitutePlaceholder replaces every OutPlaceholder in `values` with `outdir` */
+func substitutePlaceholder(values []string, outdir string) {
+	for i, value := range values {
+		values[i] = strings.ReplaceAll(value, OutPlaceholder, outdir)
+	}
+}
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ProfileEntry struct {
+	Count int
+	Total time.Duration
+}
+
+/* Profile records the inclusive wall-time spent resolving expressions, per kind and per position */
+type Profile struct {
+	mu        sync.Mutex
+	Kinds     map[string]*ProfileEntry
+	Positions map[string]*ProfileEntry
+}
+
+func NewProfile() *Profile {
+	return &Profile{
+		Kinds:     make(map[string]*ProfileEntry),
+		Positions: make(map[string]*ProfileEntry),
+	}
+}
+
+func (prof *Profile) record(expr Expression, dur time.Duration) {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", expr), "types.")
+	pos := kind + " at " + expr.Pos()
+
+	prof.mu.Lock()
+	defer prof.mu.Unlock()
+	for key, entries := range map[string]map[string]*ProfileEntry{kind: prof.Kinds, pos: prof.Positions} {
+		entry, ok := entries[key]
+		if !ok {
+			entry = &ProfileEntry{}
+			entries[key] = entry
+		}
+		entry.Count++
+		entry.Total += dur
+	}
+}
+
+/* WriteSummary writes the expression-kinds and the `n` most expensive positions,
+ * as times are inclusive, nested expressions are counted in their parents as well */
+func (prof *Profile) WriteSummary(w io.Writer, n int) {
+	prof.mu.Lock()
+	defer prof.mu.Unlock()
+
+	write := func(title string, entries map[string]*ProfileEntry, n int) {
+		keys := slices.Collect(maps.Keys(entries))
+		slices.SortFunc(keys, func(a, b string) int {
+			return cmp.Compare(entries[b].Total, entries[a].Total)
+		})
+		fmt.Fprintln(w, title)
+		for i, key := range keys {
+			if n > 0 && i >= n {
+				break
+			}
+			entry := entries[key]
+			fmt.Fprintf(w, "  %12v %8dx  %s\n", entry.Total.Round(time.Microsecond), entry.Count, key)
+		}
+	}
+	write("time per expression kind (inclusive):", prof.Kinds, 0)
+	write("most expensive expressions (inclusive):", prof.Positions, n)
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/* transfer in progress */
+type transfer struct {
+	name  string
+	done  int64
+	total int64 /* unknown if <= 0 */
+}
+
+/* progress shows the running transfers in a single line on stderr if it is a terminal */
+type progress struct {
+	mu     sync.Mutex
+	active []*transfer
+}
+
+/* formatBytes formats `size` in binary units */
+func formatBytes(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%dB", size)
+	}
+	value := float64(size)
+	suffix := ""
+	for _, suffix = range []string{"K", "M", "G", "T"} {
+		value /= 1024
+		if value < 1024 {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
+
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+/* render redraws the progress-line, mu must be held */
+func (p *progress) render() {
+	if !isTerminal(os.Stderr) {
+		return
+	}
+	var parts []string
+	for _, t := range p.active {
+		if t.total > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d%%", t.name, t.done*100/t.total))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %s", t.name, formatBytes(t.done)))
+		}
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K")
+	if len(parts) > 0 {
+		fmt.Fprintf(os.Stderr, "[%d] %s", len(parts), strings.Join(parts, ", "))
+	}
+}
+
+/* start registers a transfer of `name` and returns a reader counting the bytes read from `r`
+ * and a function which removes the transfer and prints `message` */
+func (p *progress) start(name string, total int64, r io.Reader) (io.Reader, func(message string)) {
+	t := &transfer{name: name, total: total}
+	p.mu.Lock()
+	p.active = append(p.active, t)
+	p.render()
+	p.mu.Unlock()
+
+	reader := &progressReader{r: r, p: p, t: t}
+	return reader, func(message string) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.active = slices.DeleteFunc(p.active, func(elem *transfer) bool { return elem == t })
+		if isTerminal(os.Stderr) {
+			fmt.Fprint(os.Stderr, "\r\033[K")
+		}
+		fmt.Fprintln(os.Stderr, message)
+		p.render()
+	}
+}
+
+type progressReader struct {
+	r io.Reader
+	p *progress
+	t *transfer
+}
+
+func (r *progressReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	r.p.mu.Lock()
+	r.t.done += int64(n)
+	r.p.render()
+	r.p.mu.Unlock()
+	return n, err
+}
@@ -0,0 +1,55 @@
+package types
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+/* characters of randomString */
+const randomAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+/* checkRandom rejects random values unless impure constructs are allowed explicitly */
+func (ev *Evaluator) checkRandom(call CallExpr) error {
+	if !ev.AllowImpure {
+		return evalErrorf(call.Position, "random values are impure, use --allow-impure to allow them")
+	}
+	return nil
+}
+
+/* randomString(length) returns a random alphanumeric string, only allowed with --allow-impure */
+func builtinRandomString(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 1, 1); err != nil {
+		return nil, nil, err
+	}
+	if err := ev.checkRandom(call); err != nil {
+		return nil, nil, err
+	}
+	length, err := argInt(call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if length < 0 {
+		return nil, nil, evalErrorf(args[0].Location(), "negative length: %d", length)
+	}
+	buf := make([]byte, length)
+	rand.Read(buf)
+	for i, b := range buf {
+		buf[i] = randomAlphabet[int(b)%len(randomAlphabet)]
+	}
+	return StringValue{call.Position, string(buf)}, nil, nil
+}
+
+/* uuid() returns a random version 4 UUID, only allowed with --allow-impure */
+func builtinUUID(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 0, 0); err != nil {
+		return nil, nil, err
+	}
+	if err := ev.checkRandom(call); err != nil {
+		return nil, nil, err
+	}
+	var id [16]byte
+	rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40 /* version 4 */
+	id[8] = id[8]&0x3f | 0x80 /* variant 10 */
+	return StringValue{call.Position, fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])}, nil, nil
+}
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+/* recScope returns `scope` extended by the keys of the recursive map `obj`,
+ * values are resolved lazily when they are referenced */
+func (obj MapExpr) recScope(scope Scope) (Scope, error) {
+	names := make([]string, 0, len(obj.Exprs)/2)
+	values := make(map[string]Expression)
+	for i := 0; i < len(obj.Exprs); i += 2 {
+		name, ok := ConstantKey(obj.Exprs[i])
+		if !ok {
+			return nil, evalErrorf(obj.Exprs[i].Location(), "keys of rec-maps must be constant strings")
+		}
+		if inherited(name, obj.Exprs[i+1]) {
+			/* resolved in the outer scope */
+			continue
+		}
+		names = append(names, name)
+		values[name] = obj.Exprs[i+1]
+	}
+	if cycle := recCycle(names, values); cycle != nil {
+		return nil, evalErrorf(values[cycle[0]].Location(), "cyclic reference: %s", strings.Join(cycle, " -> "))
+	}
+
+	newscope := maps.Clone(scope)
+	for _, name := range names {
+		newscope[name] = Variable{values[name], newscope}
+	}
+	return newscope, nil
+}
+
+/* inherited reports whether `expr` is the variable `name`, as with `inherit name;` */
+func inherited(name string, expr Expression) bool {
+	v, ok := expr.(VarExpr)
+	return ok && v.Name == name && len(v.Args) == 0
+}
+
+/* recCycle returns keys referencing each other in a cycle, nil if there is none */
+func recCycle(names []string, values map[string]Expression) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(stack, name)
+			return append(slices.Clone(stack[start:]), name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, other := range names {
+			if references(values[name], other) {
+				if cycle := visit(other); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
@@ -6,6 +6,33 @@ import (
 	"maps"
 )
 
+/* resolvedExpr binds an already resolved value to a variable */
+type resolvedExpr struct {
+	value Value
+	deps  []PathExpr
+}
+
+func (obj resolvedExpr) Pos() string {
+	return obj.value.Pos()
+}
+
+func (obj resolvedExpr) Location() Position {
+	return obj.value.Location()
+}
+
+func (obj resolvedExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	return obj.value, obj.deps, nil
+}
+
+func (obj resolvedExpr) hashValue(w io.Writer) {
+	fmt.Fprint(w, "resolved")
+	if expr, ok := obj.value.(Expression); ok {
+		expr.hashValue(w)
+	} else {
+		fmt.Fprint(w, obj.value.JSON())
+	}
+}
+
 type VarExpr struct {
 	Position
 
@@ -16,9 +43,12 @@ type VarExpr struct {
 func (obj VarExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
 	expr, ok := scope[obj.Name]
 	if !ok {
-		return nil, nil, fmt.Errorf("%s: not in scope: %s", obj.Pos(), obj.Name)
+		if fn, ok := builtins[obj.Name]; ok {
+			return BuiltinExpr{Position: obj.Position, Name: obj.Name, Func: fn}, nil, nil
+		}
+		return nil, nil, evalErrorf(obj.Position, "not in scope: %s", obj.Name)
 	}
-	return expr.Expr.Resolve(expr.Scope, ev)
+	return ev.Resolve(expr.Expr, expr.Scope)
 }
 
 func (obj VarExpr) hashValue(w io.Writer) {
@@ -32,29 +62,41 @@ func (obj VarExpr) hashValue(w io.Writer) {
 type AttributeExpr struct {
 	Position
 
-	Base Expression
-	Name string
+	Base     Expression
+	Name     string
+	Optional bool /* `base.?name`, null if `base` is null or has no `name` */
 }
 
 func (obj AttributeExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
-	val, deps, err := obj.Base.Resolve(scope, ev)
+	val, deps, err := ev.Resolve(obj.Base, scope)
 	if err != nil {
 		return nil, nil, err
 	}
 	switch mapval := val.(type) {
+	case NullExpr:
+		if obj.Optional {
+			return NullExpr{obj.Position}, deps, nil
+		}
+		return nil, nil, evalErrorf(mapval.Location(), "%T has no attributes", mapval)
 	case MapValue:
 		val, ok := mapval.Values[obj.Name]
+		if !ok && obj.Optional {
+			return NullExpr{obj.Position}, deps, nil
+		}
 		if !ok {
-			return nil, nil, fmt.Errorf("%s: map has no attribute %s", mapval.Pos(), obj.Name)
+			return nil, nil, evalErrorf(mapval.Location(), "map has no attribute %s", obj.Name)
 		}
 		return val, deps, nil
 	default:
-		return nil, nil, fmt.Errorf("%s: %T has no attributes", mapval.Pos(), mapval)
+		return nil, nil, evalErrorf(mapval.Location(), "%T has no attributes", mapval)
 	}
 }
 
 func (obj AttributeExpr) hashValue(w io.Writer) {
 	fmt.Fprintf(w, "attribute")
+	if obj.Optional {
+		fmt.Fprint(w, "?")
+	}
 	fmt.Fprint(w, obj.Name)
 	obj.Base.hashValue(w)
 }
@@ -67,16 +109,42 @@ type CallExpr struct {
 }
 
 func (obj CallExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
-	value, deps, err := obj.Base.Resolve(scope, ev)
+	value, deps, err := ev.Resolve(obj.Base, scope)
 	if err != nil {
 		return nil, nil, err
 	}
+	if builtin, ok := value.(BuiltinExpr); ok {
+		args, argdeps, err := parallelResolve(obj.Args, scope, ev)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, paths, err := builtin.Func(obj, args, scope, ev)
+		deps = append(deps, argdeps...)
+		deps = append(deps, paths...)
+		return res, deps, err
+	}
 	lambda, ok := value.(LambdaExpr)
 	if !ok {
-		return nil, nil, fmt.Errorf("%s: unable to call %T", obj.Pos(), value)
+		return nil, nil, evalErrorf(obj.Position, "unable to call %T", value)
+	}
+	if lambda.Attrs {
+		if len(obj.Args) != 1 {
+			return nil, nil, evalErrorf(obj.Position, "function expecting a map, got %d arguments", len(obj.Args))
+		}
+		arg, argdeps, err := ev.Resolve(obj.Args[0], scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		deps = append(deps, argdeps...)
+		newscope := maps.Clone(scope)
+		if err := lambda.bindAttrs(obj, arg, scope, newscope); err != nil {
+			return nil, nil, err
+		}
+		res, paths, err := ev.Resolve(lambda.Expr, newscope)
+		return res, append(deps, paths...), err
 	}
 	if len(lambda.Args) != len(obj.Args) {
-		return nil, nil, fmt.Errorf("%s: variable expecting %d arguments, got %d", obj.Pos(), len(lambda.Args), len(obj.Args))
+		return nil, nil, evalErrorf(obj.Position, "variable expecting %d arguments, got %d", len(lambda.Args), len(obj.Args))
 	}
 	newscope := scope
 	if len(lambda.Args) > 0 {
@@ -85,7 +153,7 @@ func (obj CallExpr) Resolve(scope Scope, ev *Evaluator) (Value, []PathExpr, erro
 			newscope[name] = Variable{obj.Args[i], scope}
 		}
 	}
-	res, paths, err := lambda.Expr.Resolve(newscope, ev)
+	res, paths, err := ev.Resolve(lambda.Expr, newscope)
 	deps = append(deps, paths...)
 	return res, deps, err
 }
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/* RootsDir is the directory in the store holding a symlink to every result-symlink, which keeps its target alive */
+const RootsDir = ".roots"
+
+/* storeDirOf returns the store containing the store-entry at `target` */
+func storeDirOf(target string) string {
+	dir := path.Dir(target)
+	if path.Base(dir) == ImpureDir {
+		dir = path.Dir(dir)
+	}
+	return dir
+}
+
+/* registerRoot records the result-symlink `link` to `target` in the store of `target` */
+func registerRoot(link string, target string) error {
+	abs, err := filepath.Abs(link)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	dir := path.Join(storeDirOf(target), RootsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := path.Join(dir, hex.EncodeToString(sum[:16]))
+	os.Remove(name)
+	return os.Symlink(abs, name)
+}
+
+/* Roots returns the store-names which are the target of a result-symlink, roots of removed symlinks are removed */
+func Roots(cachedir string) (map[string]bool, error) {
+	roots := make(map[string]bool)
+	dir := path.Join(cachedir, RootsDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return roots, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		root := path.Join(dir, entry.Name())
+		target, err := filepath.EvalSymlinks(root)
+		if err != nil || storeDirOf(target) != cachedir || strings.HasPrefix(path.Base(target), ".") {
+			os.Remove(root)
+			continue
+		}
+		roots[path.Base(target)] = true
+	}
+	return roots, nil
+}
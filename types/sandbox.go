@@ -0,0 +1,62 @@
+package types
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+/* SandboxCommand is the hidden command of zon which runs a builder with a read-only filesystem */
+const SandboxCommand = "__sandbox"
+
+/* directories a sandboxed builder must not write to, writes to them are collected and fail the build,
+ * on Linux they are redirected to an overlay, elsewhere they are compared before and after the build */
+var monitoredDirs = []string{"/usr/local", "/opt", "/etc"}
+
+/* fileState is the state of a file compared by snapshots */
+type fileState struct {
+	mode    fs.FileMode
+	size    int64
+	modtime time.Time
+}
+
+/* snapshot returns the state of every file in `dirs`, missing directories are skipped */
+func snapshot(dirs []string) map[string]fileState {
+	files := make(map[string]fileState)
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info, err := entry.Info(); err == nil {
+				files[name] = fileState{info.Mode(), info.Size(), info.ModTime()}
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+/* changedFiles returns the files which are created, changed or removed between `before` and `after` */
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+	for name, state := range after {
+		if prev, ok := before[name]; !ok || prev != state {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+/* writableDirs returns the directories a sandboxed builder in `builddir` may write to,
+ * $out is created in `staging` as the rest of the store stays read-only */
+func writableDirs(staging string, builddir string) []string {
+	return []string{staging, builddir}
+}
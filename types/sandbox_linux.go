@@ -0,0 +1,355 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysOpenTree         = 428 /* equal on every architecture */
+	sysMoveMount        = 429
+	sysMountSetattr     = 442
+	openTreeClone       = 0x1
+	moveMountEmptyPath  = 0x4
+	mountAttrReadOnly   = 0x1
+	atRecursive         = 0x8000
+	atSymlinkNoFollow   = 0x100
+	atFDCWD             = 0xffffff9c
+	sandboxSetupFailure = 126
+	reportFD            = 3 /* SandboxMain writes the files written to monitoredDirs to it */
+)
+
+/* struct mount_attr */
+type mountAttr struct {
+	set, clr, propagation, usernsFD uint64
+}
+
+func mountSetattr(dir string, attr mountAttr, recursive bool) error {
+	name, err := syscall.BytePtrFromString(dir)
+	if err != nil {
+		return err
+	}
+	flags := uintptr(atSymlinkNoFollow)
+	if recursive {
+		flags |= atRecursive
+	}
+	_, _, errno := syscall.Syscall6(sysMountSetattr, atFDCWD, uintptr(unsafe.Pointer(name)),
+		flags, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("mount_setattr %s: %w", dir, errno)
+	}
+	return nil
+}
+
+/* openTree returns a detached copy of the mount at `dir` and the mounts below it */
+func openTree(dir string) (int, error) {
+	name, err := syscall.BytePtrFromString(dir)
+	if err != nil {
+		return -1, err
+	}
+	fd, _, errno := syscall.Syscall(sysOpenTree, atFDCWD, uintptr(unsafe.Pointer(name)), openTreeClone|atRecursive|syscall.O_CLOEXEC)
+	if errno != 0 {
+		return -1, fmt.Errorf("open_tree %s: %w", dir, errno)
+	}
+	return int(fd), nil
+}
+
+/* moveMount attaches the detached mount `tree` at `dir` */
+func moveMount(tree int, dir string) error {
+	name, err := syscall.BytePtrFromString(dir)
+	if err != nil {
+		return err
+	}
+	empty := []byte{0}
+	_, _, errno := syscall.Syscall6(sysMoveMount, uintptr(tree), uintptr(unsafe.Pointer(&empty[0])),
+		atFDCWD, uintptr(unsafe.Pointer(name)), moveMountEmptyPath, 0)
+	if errno != 0 {
+		return fmt.Errorf("move_mount %s: %w", dir, errno)
+	}
+	return nil
+}
+
+/* unescapeMount decodes the octal escapes of a path in /proc/self/mountinfo */
+func unescapeMount(name string) string {
+	var builder strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			if chr, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				builder.WriteByte(byte(chr))
+				i += 3
+				continue
+			}
+		}
+		builder.WriteByte(name[i])
+	}
+	return builder.String()
+}
+
+/* submounts returns the mounts below `dir`, without the mounts below those */
+func submounts(dir string) ([]string, error) {
+	content, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	var mounts []string
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		if target := unescapeMount(fields[4]); strings.HasPrefix(target, dir+"/") {
+			mounts = append(mounts, target)
+		}
+	}
+	slices.Sort(mounts)
+	var top []string
+	for _, target := range mounts {
+		if len(top) == 0 || (target != top[len(top)-1] && !strings.HasPrefix(target, top[len(top)-1]+"/")) {
+			top = append(top, target)
+		}
+	}
+	return top, nil
+}
+
+/* overlay mounts an overlay over `dir` with its upper directory in `layer`,
+ * the mounts below `dir` are moved on top of it */
+func overlay(dir string, layer string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	upper, work := filepath.Join(layer, "upper"), filepath.Join(layer, "work")
+	if err := os.MkdirAll(work, 0700); err != nil {
+		return err
+	}
+	/* the overlay takes the attributes of the upper directory */
+	if err := os.MkdirAll(upper, 0700); err != nil {
+		return err
+	}
+	/* owners unmapped in a user-namespace cannot be set */
+	owner := info.Sys().(*syscall.Stat_t)
+	if err := os.Chown(upper, int(owner.Uid), int(owner.Gid)); err != nil && !errors.Is(err, syscall.EINVAL) {
+		return err
+	}
+	if err := os.Chmod(upper, info.Mode()&(fs.ModePerm|fs.ModeSticky|fs.ModeSetuid|fs.ModeSetgid)); err != nil {
+		return err
+	}
+
+	mounts, err := submounts(dir)
+	if err != nil {
+		return err
+	}
+	trees := make([]int, len(mounts))
+	for i, target := range mounts {
+		if trees[i], err = openTree(target); err != nil {
+			return err
+		}
+		defer syscall.Close(trees[i])
+	}
+	options := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", dir, upper, work)
+	if err := syscall.Mount("overlay", dir, "overlay", 0, options); err != nil {
+		/* overlays in a user-namespace keep their attributes in user.* extended attributes */
+		if err := syscall.Mount("overlay", dir, "overlay", 0, options+",userxattr"); err != nil {
+			return fmt.Errorf("unable to mount overlay: %w", err)
+		}
+	}
+	for i, target := range mounts {
+		if err := moveMount(trees[i], target); err != nil {
+			syscall.Unmount(dir, syscall.MNT_DETACH)
+			return err
+		}
+	}
+	return nil
+}
+
+/* overlayMonitored mounts a tmpfs at `monitor` and an overlay over every existing monitoredDirs with its upper directory in it,
+ * writes to them are collected instead of reaching the filesystem, it returns the overlaid directories */
+func overlayMonitored(monitor string) ([]string, error) {
+	if err := syscall.Mount("tmpfs", monitor, "tmpfs", 0, "mode=0700"); err != nil {
+		return nil, fmt.Errorf("unable to mount tmpfs: %w", err)
+	}
+	var overlaid []string
+	for i, dir := range monitoredDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := overlay(dir, filepath.Join(monitor, strconv.Itoa(i))); err != nil {
+			fmt.Fprintf(os.Stderr, "zon: unable to monitor writes to %s, it stays read-only: %v\n", dir, err)
+			continue
+		}
+		overlaid = append(overlaid, dir)
+	}
+	return overlaid, nil
+}
+
+/* collectWrites returns the files written to the overlays of overlayMonitored in `monitor` */
+func collectWrites(monitor string) []string {
+	var written []string
+	for i, dir := range monitoredDirs {
+		upper := filepath.Join(monitor, strconv.Itoa(i), "upper")
+		filepath.WalkDir(upper, func(name string, _ fs.DirEntry, err error) error {
+			if err != nil || name == upper {
+				return nil
+			}
+			rel, _ := filepath.Rel(upper, name)
+			written = append(written, filepath.Join(dir, rel))
+			return nil
+		})
+	}
+	return written
+}
+
+/* restrictWrites makes every mount read-only except `writable` and the overlays over `overlaid` */
+func restrictWrites(writable []string, overlaid []string) error {
+	for _, dir := range writable {
+		if err := syscall.Mount(dir, dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("unable to bind %s: %w", dir, err)
+		}
+	}
+	if err := mountSetattr("/", mountAttr{set: mountAttrReadOnly}, true); err != nil {
+		return err
+	}
+	for _, dir := range writable {
+		if err := mountSetattr(dir, mountAttr{clr: mountAttrReadOnly}, true); err != nil {
+			return err
+		}
+	}
+	/* mounts below the overlays stay read-only */
+	for _, dir := range overlaid {
+		if err := mountSetattr(dir, mountAttr{clr: mountAttrReadOnly}, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* writeMonitor receives the writes of a sandboxed builder to monitoredDirs, which are redirected to overlays */
+type writeMonitor struct {
+	dir    string   /* mountpoint of the upper directories in the sandbox */
+	report *os.File /* written files reported by SandboxMain */
+}
+
+func newWriteMonitor() (*writeMonitor, error) {
+	dir, err := os.MkdirTemp("", "zon-monitor-")
+	if err != nil {
+		return nil, err
+	}
+	report, err := os.CreateTemp("", "zon-writes-")
+	if err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+	os.Remove(report.Name())
+	return &writeMonitor{dir: dir, report: report}, nil
+}
+
+/* illegal returns the files written to monitoredDirs as reported by SandboxMain */
+func (m *writeMonitor) illegal() ([]string, error) {
+	if _, err := m.report.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(m.report)
+	if err != nil || len(content) == 0 {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n"), nil
+}
+
+func (m *writeMonitor) close() {
+	m.report.Close()
+	os.Remove(m.dir)
+}
+
+/* sandboxedCommand returns a command running `cmdline` through SandboxCommand in a new mount-namespace,
+ * the builder may only write to `writable`, writes to monitoredDirs are reported to `monitor`, it runs as `builduser` if not nil */
+func sandboxedCommand(cmdline []string, writable []string, monitor *writeMonitor, builduser *buildUser) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	user := "-"
+	if builduser != nil {
+		user = fmt.Sprintf("%d:%d", builduser.uid, builduser.gid)
+	}
+	args := append([]string{SandboxCommand, user, monitor.dir}, writable...)
+	args = append(args, "--")
+	cmd := exec.Command(exe, append(args, cmdline...)...)
+	cmd.ExtraFiles = []*os.File{monitor.report}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNS}
+	if os.Geteuid() != 0 {
+		/* unprivileged users need a user-namespace to mount, the builder keeps the capabilities
+		 * only as root in it, which is the user itself outside */
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Geteuid(), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getegid(), Size: 1}}
+	}
+	return cmd, nil
+}
+
+/* SandboxMain is SandboxCommand: `<uid:gid|-> <monitor> <writable...> -- <builder...>`,
+ * it restricts writes, runs the builder without privileges and reports its writes to monitoredDirs */
+func SandboxMain(args []string) {
+	sep := slices.Index(args, "--")
+	if sep < 2 || sep == len(args)-1 {
+		fmt.Fprintf(os.Stderr, "usage: zon %s <uid:gid|-> <monitor> <writable...> -- <builder...>\n", SandboxCommand)
+		os.Exit(sandboxSetupFailure)
+	}
+	user, monitor, writable, cmdline := args[0], args[1], args[2:sep], args[sep+1:]
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "zon: unable to set up sandbox: %v\n", err)
+		os.Exit(sandboxSetupFailure)
+	}
+	/* the builder does not inherit the report */
+	syscall.CloseOnExec(reportFD)
+	if err := syscall.Mount("none", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		fail(fmt.Errorf("unable to make mounts private: %w", err))
+	}
+	overlaid, err := overlayMonitored(monitor)
+	if err != nil {
+		fail(err)
+	}
+	if err := restrictWrites(writable, overlaid); err != nil {
+		fail(err)
+	}
+	/* the working directory still refers to the mount below the writable bind */
+	if dir, err := os.Getwd(); err == nil {
+		if err := os.Chdir(dir); err != nil {
+			fail(err)
+		}
+	}
+
+	cmd := exec.Command(cmdline[0], cmdline[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if uid, gid, ok := strings.Cut(user, ":"); ok {
+		u, _ := strconv.Atoi(uid)
+		g, _ := strconv.Atoi(gid)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: uint32(u), Gid: uint32(g)}}
+	}
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		fail(err)
+	}
+
+	report := os.NewFile(reportFD, "report")
+	for _, name := range collectWrites(monitor) {
+		fmt.Fprintln(report, name)
+	}
+	if exitErr != nil {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(exitErr.ExitCode())
+	}
+	os.Exit(0)
+}
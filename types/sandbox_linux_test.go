@@ -0,0 +1,95 @@
+package types_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+)
+
+/* sandboxed builders are run through the test binary itself */
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == types.SandboxCommand {
+		types.SandboxMain(os.Args[2:])
+		return
+	}
+	os.Exit(m.Run())
+}
+
+/* buildSandboxed builds an output running `script` in the sandbox */
+func buildSandboxed(t *testing.T, script string) (string, error) {
+	t.Helper()
+	src := `output { "name": "sandboxed", "output": ` + "''" + script + "''" + ` }`
+	ast, err := parser.ParseString(src, filepath.Join(t.TempDir(), "test.zon"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &types.Evaluator{
+		ParseFile:    parser.ParseFile,
+		CacheDir:     t.TempDir(),
+		LogDir:       t.TempDir(),
+		Interpreter:  "sh",
+		Sandbox:      true,
+		BuildUser:    "root",
+		LogTailLines: 20,
+	}
+	value, _, err := ev.Resolve(ast, make(types.Scope))
+	var buildErr *types.BuildError
+	if errors.As(err, &buildErr) && strings.Contains(buildErr.Tail, "unable to set up sandbox") {
+		t.Skipf("sandbox not available: %s", buildErr.Tail)
+	}
+	if err != nil {
+		return "", err
+	}
+	return value.(types.PathExpr).Name, nil
+}
+
+func TestSandbox(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "outside")
+	os.Chmod(filepath.Dir(outside), 0777)
+	tests := []struct {
+		name   string
+		script string
+		err    string /* empty if the build succeeds */
+	}{
+		{"output", `mkdir -p $out/bin && echo hi > $out/bin/tool`, ""},
+		{"file output", `echo hi > $out`, ""},
+		{"build directory", `echo a > ./file && echo b > $TMPDIR/file && cat ./file $TMPDIR/file > $out`, ""},
+		{"environment", `test "$TZ" = UTC && test "$LC_ALL" = C && test -n "$SOURCE_DATE_EPOCH" && touch $out`, ""},
+		{"store", `mkdir $out && touch $out/../../other`, "Read-only file system"},
+		{"outside", `touch ` + outside, "Read-only file system"},
+		{"root", `touch /zon-sandbox-test`, "Read-only file system"},
+		{"monitored", `echo x > /etc/zon-sandbox-test; touch $out`, "illegal writes outside $out and the build directory:\n  /etc/zon-sandbox-test"},
+		{"ignored write", `mkdir -p /usr/local/zon-sandbox-test/dir || true; touch $out`, "/usr/local/zon-sandbox-test/dir"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outdir, err := buildSandboxed(t, test.script)
+			if test.err != "" {
+				var buildErr *types.BuildError
+				if !errors.As(err, &buildErr) || !strings.Contains(buildErr.Error(), test.err) {
+					t.Fatalf("got error %v, want build error with %q", err, test.err)
+				}
+				if entries, _ := os.ReadDir(filepath.Dir(buildErr.LogPath)); len(entries) == 0 {
+					t.Errorf("log of failed build is missing")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := os.Stat(outdir); err != nil {
+				t.Errorf("output is missing: %v", err)
+			}
+		})
+	}
+	for _, name := range []string{outside, "/etc/zon-sandbox-test", "/usr/local/zon-sandbox-test"} {
+		if _, err := os.Stat(name); err == nil {
+			t.Errorf("sandboxed builder wrote %s", name)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+
+package types
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+/* writeMonitor detects writes by comparing monitoredDirs, as the filesystem cannot be made read-only */
+type writeMonitor struct {
+	before map[string]fileState
+}
+
+func newWriteMonitor() (*writeMonitor, error) {
+	return &writeMonitor{before: snapshot(monitoredDirs)}, nil
+}
+
+/* illegal returns the files in monitoredDirs which are created, changed or removed since newWriteMonitor */
+func (m *writeMonitor) illegal() ([]string, error) {
+	return changedFiles(m.before, snapshot(monitoredDirs)), nil
+}
+
+func (m *writeMonitor) close() {}
+
+func sandboxedCommand(cmdline []string, writable []string, monitor *writeMonitor, builduser *buildUser) (*exec.Cmd, error) {
+	cmd := exec.Command(cmdline[0], cmdline[1:]...)
+	if builduser != nil {
+		builduser.apply(cmd)
+	}
+	return cmd, nil
+}
+
+/* SandboxMain is not supported on this platform */
+func SandboxMain(args []string) {
+	fmt.Fprintf(os.Stderr, "zon: %s is not supported on this platform\n", SandboxCommand)
+	os.Exit(1)
+}
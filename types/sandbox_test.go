@@ -0,0 +1,39 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		name = filepath.Join(dir, name)
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	kept := write("kept", "a")
+	changed := write("changed", "a")
+	removed := write("removed", "a")
+	before := snapshot([]string{dir, filepath.Join(dir, "missing")})
+
+	os.Chtimes(changed, time.Now(), time.Now().Add(time.Hour))
+	os.Remove(removed)
+	created := write("created", "a")
+
+	got := changedFiles(before, snapshot([]string{dir}))
+	/* the directory itself changed by creating and removing files */
+	want := []string{dir, changed, created, removed}
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if slices.Contains(got, kept) {
+		t.Errorf("unchanged file %s is reported", kept)
+	}
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+/* names which are passed to the builder as environment-variable */
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type attrType struct {
+	desc  string
+	valid func(Value) bool
+}
+
+/* expected type of every attribute interpreted by zon */
+var outputSchema = map[string]attrType{
+	"name":          {"a string", isType[StringValue]},
+	"output":        {"a string", isType[StringValue]},
+	"builder":       {"a string", isType[StringValue]},
+	"interpreter":   {"a string", isType[StringValue]},
+	"source":        {"a path", isType[PathExpr]},
+	"encoding":      {"a string", isType[StringValue]},
+	"impure":        {"a boolean", isType[BooleanExpr]},
+	"sourceInPlace": {"a boolean", isType[BooleanExpr]},
+	"mainProgram":   {"a string", isType[StringValue]},
+	"env":           {"a map", isType[MapValue]},
+	"secrets": {"a map of strings", func(v Value) bool {
+		m, ok := v.(MapValue)
+		return ok && !slices.ContainsFunc(slices.Collect(maps.Values(m.Values)), func(elem Value) bool { return !isType[StringValue](elem) })
+	}},
+	"stdin": {"a string or path", func(v Value) bool {
+		return isType[StringValue](v) || isType[PathExpr](v)
+	}},
+	"args":   {"an array of strings", isStrings},
+	"strip":  {"an array of strings", isStrings},
+	"redact": {"an array of strings", isStrings},
+}
+
+func isStrings(v Value) bool {
+	arr, ok := v.(ArrayValue)
+	return ok && !slices.ContainsFunc(arr.Values, func(elem Value) bool { return !isType[StringValue](elem) })
+}
+
+func isType[T Value](v Value) bool {
+	_, ok := v.(T)
+	return ok
+}
+
+/* validateOutput checks the attributes of an output before it is built,
+ * all problems are reported at once, unknown attributes and variables which cannot be passed to the builder are warned */
+func (ev *Evaluator) validateOutput(obj OutputExpr, result MapValue) error {
+	var errs []error
+	if _, ok := result.Values["name"]; !ok {
+		errs = append(errs, evalErrorf(obj.Position, "output has no attribute 'name'"))
+	}
+	_, hasOutput := result.Values["output"]
+	_, hasBuilder := result.Values["builder"]
+	if !hasOutput && !hasBuilder {
+		errs = append(errs, evalErrorf(obj.Position, "missing output or builder"))
+	}
+	environ := ev.outputEnviron(result)
+	for _, key := range slices.Sorted(maps.Keys(result.Values)) {
+		value := result.Values[key]
+		typ, known := outputSchema[key]
+		if !known {
+			/* with --legacy-env unknown attributes are passed to the builder */
+			if _, ok := environ[key]; !ok {
+				ev.warnf(value.Location(), "unknown output attribute '%s' is ignored, use 'env' to pass it to the builder", key)
+			}
+		} else if !typ.valid(value) {
+			errs = append(errs, evalErrorf(value.Location(), "output attribute '%s' should be %s, got %T", key, typ.desc, value))
+		}
+	}
+	for key, value := range environ {
+		if !envName.MatchString(key) {
+			ev.warnf(value.Location(), "attribute '%s' is not a valid environment-variable", key)
+		}
+	}
+	if enc, ok := result.Values["encoding"].(StringValue); ok && !slices.Contains([]string{"plain", "json", "prefix"}, enc.Content) {
+		errs = append(errs, evalErrorf(enc.Position, "unknown environment-encoding: %s", enc.Content))
+	}
+	if len(errs) > 0 {
+		return evalErrorf(obj.Position, "invalid output:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package types_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/friedelschoen/zon/parser"
+	"github.com/friedelschoen/zon/types"
+)
+
+func TestUnknownAttributes(t *testing.T) {
+	const src = `output { "name": "pkg", "output": "true", "zeta": "z", "env": { "alpha": "a" } }`
+	tests := []struct {
+		name      string
+		legacyEnv bool
+		want      []string
+	}{
+		{"ignored", false, []string{"unknown output attribute 'zeta' is ignored, use 'env' to pass it to the builder"}},
+		{"legacy environment", true, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ast, err := parser.ParseString(src, filepath.Join(t.TempDir(), "test.zon"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ev := &types.Evaluator{
+				ParseFile: parser.ParseFile,
+				CacheDir:  t.TempDir(),
+				LogDir:    t.TempDir(),
+				DryRun:    true,
+				LegacyEnv: test.legacyEnv,
+			}
+			if _, _, err := ev.Resolve(ast, make(types.Scope)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, warn := range ev.Warnings {
+				got = append(got, warn.Message)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got warnings %q, want %q", got, test.want)
+			}
+		})
+	}
+}
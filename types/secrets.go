@@ -0,0 +1,113 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/* secrets of builders, read from a file of name=value lines or from a command printing the value of its argument */
+type Secrets struct {
+	File    string
+	Command string
+
+	once   sync.Once
+	values map[string]string
+	err    error
+}
+
+func (s *Secrets) readFile() {
+	s.values = make(map[string]string)
+	if s.File == "" {
+		return
+	}
+	file, err := os.Open(s.File)
+	if err != nil {
+		s.err = err
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			s.err = fmt.Errorf("%s: invalid line, expected name=value", s.File)
+			return
+		}
+		s.values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	s.err = scanner.Err()
+}
+
+/* get returns the secret `name`, the file is preferred over the command */
+func (s *Secrets) get(name string) (string, error) {
+	s.once.Do(s.readFile)
+	if s.err != nil {
+		return "", s.err
+	}
+	if value, ok := s.values[name]; ok {
+		return value, nil
+	}
+	if s.Command == "" {
+		return "", fmt.Errorf("unknown secret: %s", name)
+	}
+	out, err := exec.Command(s.Command, name).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", s.Command, name, err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+/* redactWriter replaces every occurrence of `secrets` and matches of `patterns` by *** before writing lines to `w` */
+type redactWriter struct {
+	w        io.Writer
+	secrets  []string
+	patterns []*regexp.Regexp
+	partial  []byte
+}
+
+func (r *redactWriter) redact(line []byte) []byte {
+	for _, secret := range r.secrets {
+		if secret != "" {
+			line = bytes.ReplaceAll(line, []byte(secret), []byte("***"))
+		}
+	}
+	for _, pattern := range r.patterns {
+		line = pattern.ReplaceAllLiteral(line, []byte("***"))
+	}
+	return line
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	r.partial = append(r.partial, p...)
+	idx := bytes.LastIndexByte(r.partial, '\n')
+	if idx == -1 {
+		return len(p), nil
+	}
+	if _, err := r.w.Write(r.redact(r.partial[:idx+1])); err != nil {
+		return 0, err
+	}
+	r.partial = slices.Clone(r.partial[idx+1:])
+	return len(p), nil
+}
+
+/* Flush writes an unterminated last line */
+func (r *redactWriter) Flush() error {
+	if len(r.partial) == 0 {
+		return nil
+	}
+	_, err := r.w.Write(r.redact(r.partial))
+	r.partial = nil
+	return err
+}
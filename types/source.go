@@ -0,0 +1,82 @@
+package types
+
+import (
+	"cmp"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+/* fileType returns the type of `entry` as passed to the predicate of filterSource */
+func fileType(entry fs.DirEntry) string {
+	switch {
+	case entry.Type().IsDir():
+		return "directory"
+	case entry.Type().IsRegular():
+		return "regular"
+	case entry.Type()&fs.ModeSymlink != 0:
+		return "symlink"
+	}
+	return "unknown"
+}
+
+/* filterSource(dir, fn(path, type) ...) copies the entries of `dir` for which the predicate is true into the store,
+ * `type` is regular, directory, symlink or unknown; the store-entry is named by its content, so other files do not
+ * change the hash of outputs using it */
+func builtinFilterSource(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	src, err := argValue[PathExpr](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := path.Base(src.Name)
+
+	if err := os.MkdirAll(ev.CacheDir, 0755); err != nil {
+		return nil, nil, evalErrorf(call.Position, "unable to create store: %w", err)
+	}
+	tmpdir, err := os.MkdirTemp(ev.CacheDir, ".filter-")
+	if err != nil {
+		return nil, nil, evalErrorf(call.Position, "%w", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	var deps []PathExpr
+	err = copyTreeFunc(src.Name, path.Join(tmpdir, name), func(filename string, entry fs.DirEntry) (bool, error) {
+		res, paths, err := callFunction(call, args[1], []Value{
+			StringValue{call.Position, filename},
+			StringValue{call.Position, fileType(entry)},
+		}, scope, ev)
+		if err != nil {
+			return false, err
+		}
+		deps = append(deps, paths...)
+		return res.Boolean()
+	})
+	if err != nil {
+		return nil, nil, evalErrorf(call.Position, "unable to filter %s: %w", src.Name, err)
+	}
+
+	hash, err := ContentHash(path.Join(tmpdir, name), cmp.Or(ev.HashAlgorithm, DefaultHash))
+	if err != nil {
+		return nil, nil, evalErrorf(call.Position, "%w", err)
+	}
+	_, digest, _ := strings.Cut(hash, ":")
+	storename := ev.storeNameHex(digest, name)
+	if err := ev.checkCollision(call.Position, storename, hash); err != nil {
+		return nil, nil, err
+	}
+	outpath := path.Join(ev.CacheDir, storename)
+	cached := true
+	if _, err := os.Stat(outpath); err != nil {
+		if err := os.Rename(path.Join(tmpdir, name), outpath); err != nil {
+			return nil, nil, evalErrorf(call.Position, "%w", err)
+		}
+		cached = false
+	}
+	ev.addInput(name, hash, outpath, cached)
+	res := PathExpr{Position: call.Position, Name: outpath, OutputName: name}
+	return res, append(deps, res), nil
+}
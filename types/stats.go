@@ -0,0 +1,41 @@
+package types
+
+import (
+	"sync"
+	"time"
+)
+
+/* counters of the builds of an evaluation, safe for concurrent use */
+type Stats struct {
+	mu sync.Mutex
+
+	Failed     int           /* builds which returned an error */
+	BuildTime  time.Duration /* wall-time where at least one build was running */
+	PeakBuilds int           /* maximum of builds running at the same time */
+
+	active int
+	since  time.Time
+}
+
+/* begin records the start of a build and returns a function recording its end */
+func (stats *Stats) begin() func(failed bool) {
+	stats.mu.Lock()
+	if stats.active == 0 {
+		stats.since = time.Now()
+	}
+	stats.active++
+	stats.PeakBuilds = max(stats.PeakBuilds, stats.active)
+	stats.mu.Unlock()
+
+	return func(failed bool) {
+		stats.mu.Lock()
+		defer stats.mu.Unlock()
+		stats.active--
+		if stats.active == 0 {
+			stats.BuildTime += time.Since(stats.since)
+		}
+		if failed {
+			stats.Failed++
+		}
+	}
+}
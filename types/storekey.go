@@ -0,0 +1,47 @@
+package types
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+)
+
+/* DefaultStoreKeyLength is the length of store-keys if none is configured, 160 bit */
+const DefaultStoreKeyLength = 32
+
+/* alphabet of store-keys, like other stores it omits e, o, u and t */
+var storeKeyEncoding = base32.NewEncoding("0123456789abcdfghijklmnpqrsvwxyz").WithPadding(base32.NoPadding)
+
+/* storeName returns the name of the store-entry `name` with hash `sum`, truncated to StoreKeyLength characters */
+func (ev *Evaluator) storeName(sum []byte, name string) string {
+	key := storeKeyEncoding.EncodeToString(sum)
+	length := ev.StoreKeyLength
+	if length <= 0 {
+		length = DefaultStoreKeyLength
+	}
+	if length < len(key) {
+		key = key[:length]
+	}
+	return key + "-" + name
+}
+
+/* storeNameHex is storeName of a hex-encoded `digest` */
+func (ev *Evaluator) storeNameHex(digest string, name string) string {
+	sum, _ := hex.DecodeString(digest)
+	return ev.storeName(sum, name)
+}
+
+/* checkCollision fails if `storename` is recorded in the index with another hash than `hash` */
+func (ev *Evaluator) checkCollision(pos Position, storename string, hash string) error {
+	content, err := os.ReadFile(path.Join(ev.CacheDir, IndexDir, storename+".json"))
+	if err != nil {
+		return nil
+	}
+	var entry IndexEntry
+	if err := json.Unmarshal(content, &entry); err != nil || entry.Hash == hash {
+		return nil
+	}
+	return evalErrorf(pos, "store-key collision: %s is stored with hash %s, not %s, increase the store-key length", storename, entry.Hash, hash)
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"errors"
+	"maps"
+	"slices"
+)
+
+/* checkStrict rejects unknown attributes in `result`, every unknown attribute is reported */
+func checkStrict(result MapValue) error {
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(result.Values)) {
+		if _, ok := outputSchema[key]; !ok {
+			errs = append(errs, evalErrorf(result.Values[key].Location(), "unknown output attribute %s", key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/* checkCoercion rejects values which would be coerced to a string in the environment, using `encoding` */
+func checkCoercion(value Value, encoding string) error {
+	switch value := value.(type) {
+	case StringValue, PathExpr:
+		return nil
+	case MapValue:
+		if encoding != "plain" {
+			for _, elem := range value.Values {
+				if err := checkCoercion(elem, encoding); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case ArrayValue:
+		if encoding != "plain" {
+			for _, elem := range value.Values {
+				if err := checkCoercion(elem, encoding); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return evalErrorf(value.Location(), "implicit coercion of %T to string", value)
+}
+
+/* checkImpure rejects `what` in strict mode, unless impure constructs are allowed */
+func (ev *Evaluator) checkImpure(pos Position, what string) error {
+	if ev.Strict && !ev.AllowImpure {
+		return evalErrorf(pos, "%s is impure, use --allow-impure to allow it in strict mode", what)
+	}
+	return nil
+}
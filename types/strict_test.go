@@ -0,0 +1,36 @@
+package types
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheckStrict(t *testing.T) {
+	result := MapValue{Values: map[string]Value{
+		"name":   StringValue{Content: "pkg"},
+		"output": StringValue{Content: "true"},
+		"zeta":   StringValue{Content: "z"},
+		"alpha":  StringValue{Content: "a"},
+		"middle": StringValue{Content: "m"},
+	}}
+	err := checkStrict(result)
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("got %v, want joined errors", err)
+	}
+	var got []string
+	for _, e := range joined.Unwrap() {
+		got = append(got, e.(*EvalError).Err.Error())
+	}
+	want := []string{"unknown output attribute alpha", "unknown output attribute middle", "unknown output attribute zeta"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	delete(result.Values, "zeta")
+	delete(result.Values, "alpha")
+	delete(result.Values, "middle")
+	if err := checkStrict(result); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
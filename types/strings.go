@@ -0,0 +1,67 @@
+package types
+
+import "strings"
+
+/* clampIndex resolves index `i` into a sequence of `length`, counting from the end if negative */
+func clampIndex(i int, length int) int {
+	if i < 0 {
+		i += length
+	}
+	return min(max(i, 0), length)
+}
+
+/* substring(str, start) or substring(str, start, end) returns the characters from `start` up to `end`,
+ * negative indices count from the end */
+func builtinSubstring(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 3); err != nil {
+		return nil, nil, err
+	}
+	str, err := argValue[StringValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	runes := []rune(str.Content)
+	start, err := argInt(call, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	end := len(runes)
+	if len(args) > 2 {
+		if end, err = argInt(call, args, 2); err != nil {
+			return nil, nil, err
+		}
+	}
+	start, end = clampIndex(start, len(runes)), clampIndex(end, len(runes))
+	if end < start {
+		end = start
+	}
+	return StringValue{call.Position, string(runes[start:end])}, nil, nil
+}
+
+/* length(str) returns the number of characters of a string */
+func builtinLength(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 1, 1); err != nil {
+		return nil, nil, err
+	}
+	str, err := argValue[StringValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NumberExpr{call.Position, float64(len([]rune(str.Content)))}, nil, nil
+}
+
+/* contains(str, substr) returns whether `substr` is part of `str` */
+func builtinContains(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	str, err := argValue[StringValue](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	substr, err := argValue[StringValue](call, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return BooleanExpr{call.Position, strings.Contains(str.Content, substr.Content)}, nil, nil
+}
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"path"
+	"strings"
+)
+
+/* subpath(path, "bin/foo") returns the path of a file inside `path`, which depends on `path` */
+func builtinSubpath(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	parent, err := argValue[PathExpr](call, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	sub, err := argValue[StringValue](call, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel := path.Clean(sub.Content)
+	if path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil, nil, evalErrorf(sub.Position, "subpath %s is not inside of %s", sub.Content, parent.Name)
+	}
+	res := PathExpr{
+		Position:   call.Position,
+		Name:       path.Join(parent.Name, rel),
+		OutputName: parent.OutputName,
+		Depends:    []PathExpr{parent},
+	}
+	return res, nil, nil
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+/* errNotSubstitutable is returned if a substituter does not have a store-entry */
+var errNotSubstitutable = errors.New("not available")
+
+/* openSubstitute opens the archive of `storename` at `substituter`,
+ * which is an url, requested with the proxy and credentials of HTTP, or a directory holding `<storename>.tar.zst` created by `zon export` */
+func (ev *Evaluator) openSubstitute(substituter string, storename string) (io.ReadCloser, int64, error) {
+	name := strings.TrimSuffix(substituter, "/") + "/" + storename + ".tar.zst"
+	if scheme, _, ok := strings.Cut(substituter, "://"); ok && scheme != "http" && scheme != "https" && scheme != "file" {
+		/* it would be taken as a directory which never has the entry */
+		return nil, 0, fmt.Errorf("unsupported substituter: %s", substituter)
+	}
+	if !strings.HasPrefix(name, "http://") && !strings.HasPrefix(name, "https://") {
+		file, err := os.Open(strings.TrimPrefix(name, "file://"))
+		if os.IsNotExist(err) {
+			return nil, 0, errNotSubstitutable
+		} else if err != nil {
+			return nil, 0, err
+		}
+		stat, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		return file, stat.Size(), nil
+	}
+	resp, err := ev.httpGet(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, resp.ContentLength, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, 0, errNotSubstitutable
+	}
+	resp.Body.Close()
+	return nil, 0, fmt.Errorf("%s: %s", name, resp.Status)
+}
+
+/* substitute tries to fetch `storename` from every substituter, returns whether it is in the store now,
+ * failing substituters are reported and the output is built locally */
+func (ev *Evaluator) substitute(storename string) bool {
+	for _, substituter := range ev.Substituters {
+		err := ev.substituteFrom(substituter, storename)
+		if err == nil {
+			return true
+		}
+		if !errors.Is(err, errNotSubstitutable) {
+			fmt.Fprintf(os.Stderr, "unable to substitute %s from %s: %v\n", storename, substituter, err)
+		}
+	}
+	return false
+}
+
+func (ev *Evaluator) substituteFrom(substituter string, storename string) error {
+	body, size, err := ev.openSubstitute(substituter, storename)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	os.MkdirAll(ev.CacheDir, 0755)
+	reader, done := ev.progress.start(storename, size, body)
+	_, _, err = ImportArchive(reader, ev.CacheDir, storename, false)
+	if err != nil {
+		done(fmt.Sprintf("%s (substitution failed)", storename))
+		return err
+	}
+	done(fmt.Sprintf("%s (substituted from %s)", storename, substituter))
+	return nil
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+/* tailWriter keeps the last `max` lines written to it */
+type tailWriter struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx == -1 {
+			break
+		}
+		w.lines = append(w.lines, string(w.partial[:idx]))
+		w.partial = w.partial[idx+1:]
+		if len(w.lines) > w.max {
+			w.lines = w.lines[len(w.lines)-w.max:]
+		}
+	}
+	return len(p), nil
+}
+
+/* String returns the kept lines, including an unterminated last line */
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	lines := w.lines
+	if len(w.partial) > 0 {
+		lines = append(lines[:len(lines):len(lines)], string(w.partial))
+		if len(lines) > w.max {
+			lines = lines[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
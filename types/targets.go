@@ -0,0 +1,55 @@
+package types
+
+import "strconv"
+
+/* output found in the AST */
+type Target struct {
+	Position
+	Name string   /* name-attribute of the output, empty if it is not constant */
+	Path []string /* attribute path of the output, array-elements are indexed */
+}
+
+/* Targets returns every output expression in `expr` without resolving it, includes are not followed */
+func Targets(expr Expression) []Target {
+	var targets []Target
+	var walk func(expr Expression, path []string)
+	walk = func(expr Expression, path []string) {
+		switch obj := expr.(type) {
+		case OutputExpr:
+			target := Target{Position: obj.Position, Path: path}
+			if attrs, ok := obj.Attrs.(MapExpr); ok {
+				for i := 0; i+1 < len(attrs.Exprs); i += 2 {
+					if key, ok := ConstantKey(attrs.Exprs[i]); ok && key == "name" {
+						target.Name, _ = ConstantKey(attrs.Exprs[i+1])
+					}
+				}
+			}
+			targets = append(targets, target)
+			walk(obj.Attrs, path)
+			return
+		case MapExpr:
+			for _, ext := range obj.Extends {
+				walk(ext, path)
+			}
+			for i := 0; i+1 < len(obj.Exprs); i += 2 {
+				walk(obj.Exprs[i], path)
+				if key, ok := ConstantKey(obj.Exprs[i]); ok {
+					walk(obj.Exprs[i+1], append(path[:len(path):len(path)], key))
+				} else {
+					walk(obj.Exprs[i+1], append(path[:len(path):len(path)], "?"))
+				}
+			}
+			return
+		case ArrayExpr:
+			for i, elem := range obj.Exprs {
+				walk(elem, append(path[:len(path):len(path)], strconv.Itoa(i)))
+			}
+			return
+		}
+		for _, child := range children(expr) {
+			walk(child, path)
+		}
+	}
+	walk(expr, nil)
+	return targets
+}
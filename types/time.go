@@ -0,0 +1,11 @@
+package types
+
+import "time"
+
+/* currentTime() returns the current time in seconds since the epoch, outputs using it are impure */
+func builtinCurrentTime(call CallExpr, args []Value, scope Scope, ev *Evaluator) (Value, []PathExpr, error) {
+	if err := expectArgs(call, args, 0, 0); err != nil {
+		return nil, nil, err
+	}
+	return NumberExpr{call.Position, float64(time.Now().Unix())}, nil, nil
+}
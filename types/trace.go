@@ -0,0 +1,40 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Trace writes every resolved expression with the type of its value and the time it took, indented by depth,
+ * an expression is written once it is resolved, after the expressions it contains */
+type Trace struct {
+	mu    sync.Mutex
+	w     io.Writer
+	depth int
+}
+
+func NewTrace(w io.Writer) *Trace {
+	return &Trace{w: w}
+}
+
+func (trace *Trace) enter() {
+	trace.mu.Lock()
+	trace.depth++
+	trace.mu.Unlock()
+}
+
+func (trace *Trace) leave(expr Expression, val Value, err error, dur time.Duration) {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", expr), "types.")
+	result := "error"
+	if err == nil {
+		result = strings.TrimPrefix(fmt.Sprintf("%T", val), "types.")
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.depth--
+	fmt.Fprintf(trace.w, "%s%s %s -> %s (%v)\n", strings.Repeat("  ", trace.depth), expr.Pos(), kind, result, dur.Round(time.Microsecond))
+}
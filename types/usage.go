@@ -0,0 +1,36 @@
+package types
+
+import (
+	"os"
+	"time"
+)
+
+/* resources used by a builder */
+type Usage struct {
+	Wall   time.Duration `json:"wall"`
+	User   time.Duration `json:"user"`   /* cpu-time spent in the builder */
+	System time.Duration `json:"system"` /* cpu-time spent in the kernel on behalf of the builder */
+	MaxRSS int64         `json:"maxRSS"` /* peak resident memory of the builder and its children in bytes, 0 if unknown */
+}
+
+/* processUsage returns the resources used by the exited process of `state`, which ran for `wall` */
+func processUsage(state *os.ProcessState, wall time.Duration) Usage {
+	if state == nil {
+		return Usage{Wall: wall}
+	}
+	return Usage{
+		Wall:   wall,
+		User:   state.UserTime(),
+		System: state.SystemTime(),
+		MaxRSS: maxRSS(state),
+	}
+}
+
+func (u Usage) JSON() any {
+	return map[string]any{
+		"wall":   u.Wall.Seconds(),
+		"user":   u.User.Seconds(),
+		"system": u.System.Seconds(),
+		"maxRSS": u.MaxRSS,
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package types
+
+import "os"
+
+/* maxRSS returns 0, the peak memory of processes is not known on this platform */
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package types
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+/* maxRSS returns the peak resident memory of the process of `state` and its waited-for children in bytes */
+func maxRSS(state *os.ProcessState) int64 {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		/* bytes instead of kilobytes */
+		return int64(rusage.Maxrss)
+	}
+	return int64(rusage.Maxrss) * 1024
+}
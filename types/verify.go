@@ -0,0 +1,55 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+)
+
+/* verifyEntry checks whether store-entry `storename` is complete and unmodified, the index-entry is written after
+ * a successful build, so entries without one are partial */
+func (ev *Evaluator) verifyEntry(storename string) error {
+	content, err := os.ReadFile(path.Join(ev.CacheDir, IndexDir, storename+".json"))
+	if err != nil {
+		return fmt.Errorf("not indexed, possibly incomplete")
+	}
+	var entry IndexEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return fmt.Errorf("invalid index-entry: %w", err)
+	}
+	if entry.Content == "" {
+		/* indexed by an older version, completeness is all we know */
+		return nil
+	}
+	algorithm, _, err := SplitHash(entry.Content)
+	if err != nil {
+		return err
+	}
+	hash, err := ContentHash(path.Join(ev.CacheDir, storename), algorithm)
+	if err != nil {
+		return err
+	}
+	if hash != entry.Content {
+		return fmt.Errorf("content changed, expected %s, got %s", entry.Content, hash)
+	}
+	return nil
+}
+
+/* reusable reports whether the cached store-entry `storename` may be reused, with Verify a corrupt entry is removed
+ * so it is rebuilt or fetched again */
+func (ev *Evaluator) reusable(storename string) bool {
+	if !ev.Verify {
+		return true
+	}
+	err := ev.verifyEntry(storename)
+	if err == nil {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "%s is corrupt: %v, rebuilding\n", storename, err)
+	if !ev.DryRun {
+		os.RemoveAll(path.Join(ev.CacheDir, storename))
+		RemoveIndex(ev.CacheDir, storename)
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"maps"
+	"slices"
+)
+
+/* children returns the expressions directly contained in `expr` */
+func children(expr Expression) []Expression {
+	switch expr := expr.(type) {
+	case MapExpr:
+		return append(slices.Clone(expr.Extends), expr.Exprs...)
+	case ArrayExpr:
+		return expr.Exprs
+	case StringExpr:
+		var interp []Expression
+		for _, e := range expr.Interp {
+			if e != nil {
+				interp = append(interp, e)
+			}
+		}
+		return interp
+	case IncludeExpr:
+		res := []Expression{expr.Name}
+		for _, over := range expr.overrides {
+			res = append(res, over.value)
+		}
+		return res
+	case DefineExpr:
+		var res []Expression
+		for _, name := range slices.Sorted(maps.Keys(expr.Define)) {
+			res = append(res, expr.Define[name])
+		}
+		return append(res, expr.Expr)
+	case InputsExpr:
+		return []Expression{expr.Inputs, expr.Expr}
+	case LambdaExpr:
+		var res []Expression
+		for _, name := range expr.Args {
+			if def, ok := expr.Defaults[name]; ok {
+				res = append(res, def)
+			}
+		}
+		return append(res, expr.Expr)
+	case ConditionExpr:
+		return []Expression{expr.Cond, expr.Truly, expr.Falsy}
+	case OperationExpr:
+		return []Expression{expr.Left, expr.Right}
+	case OutputExpr:
+		return []Expression{expr.Attrs}
+	case VarExpr:
+		return expr.Args
+	case AttributeExpr:
+		return []Expression{expr.Base}
+	case CallExpr:
+		return append([]Expression{expr.Base}, expr.Args...)
+	}
+	return nil
+}
+
+/* Walk calls `fn` for `expr` and every expression contained in it, depth-first,
+ * the children of an expression are skipped if `fn` returns false */
+func Walk(expr Expression, fn func(Expression) bool) {
+	if !fn(expr) {
+		return
+	}
+	for _, child := range children(expr) {
+		Walk(child, fn)
+	}
+}
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+)
+
+/* non-fatal diagnostic found during evaluation */
+type Warning struct {
+	Position
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: warning: %s", w.Pos(), w.Message)
+}
+
+/* warnf records a warning at `pos`, expressions may be resolved more than once so duplicates are dropped */
+func (ev *Evaluator) warnf(pos Position, format string, args ...any) {
+	warn := Warning{Position: pos, Message: fmt.Sprintf(format, args...)}
+
+	ev.warnMu.Lock()
+	defer ev.warnMu.Unlock()
+	if !slices.Contains(ev.Warnings, warn) {
+		ev.Warnings = append(ev.Warnings, warn)
+	}
+}
+
+/* references reports whether `name` is used as variable in `expr` */
+func references(expr Expression, name string) bool {
+	found := false
+	Walk(expr, func(e Expression) bool {
+		if v, ok := e.(VarExpr); ok && v.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* parseAge parses a duration like time.ParseDuration but also accepts days (30d) and weeks (2w) */
+func parseAge(str string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if num, ok := strings.CutSuffix(str, suffix); ok {
+			n, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid age: %s", str)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+	return time.ParseDuration(str)
+}
+
+/* parseSize parses a size in bytes with an optional K, M, G or T suffix */
+func parseSize(str string) (int64, error) {
+	unit := int64(1)
+	for i, suffix := range []string{"K", "M", "G", "T"} {
+		if num, ok := strings.CutSuffix(strings.ToUpper(str), suffix); ok {
+			str = num
+			unit = 1 << (10 * (i + 1))
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", str)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+/* sizeValue is a flag of a size parsed by parseSize */
+type sizeValue struct {
+	dest *int64
+}
+
+func (v sizeValue) String() string {
+	if v.dest == nil {
+		return ""
+	}
+	return formatSize(*v.dest)
+}
+
+func (v sizeValue) Set(value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	*v.dest = size
+	return nil
+}
+
+func (v sizeValue) Type() string {
+	return "size"
+}
+
+/* formatSize formats `size` in bytes human-readable */
+func formatSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%dB", size)
+	}
+	value := float64(size)
+	for _, suffix := range []string{"K", "M", "G", "T"} {
+		value /= 1024
+		if value < 1024 || suffix == "T" {
+			return fmt.Sprintf("%.1f%s", value, suffix)
+		}
+	}
+	return ""
+}
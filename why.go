@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+func whyMain(args []string) {
+	var ev types.Evaluator
+
+	flags := flag.NewFlagSet("why", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon why [options] <store-path|name|hash>\n")
+		flags.PrintDefaults()
+	}
+	storeFlags(flags, &ev)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	/* result-symlinks and store paths address a single store-entry, names and hashes may match several */
+	var matches []types.IndexEntry
+	if _, err := os.Lstat(flags.Arg(0)); err == nil {
+		index, err := types.ReadIndex(ev.CacheDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		name, err := storeEntry(ev.CacheDir, index, flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		entry, ok := index[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s is not in the index of the store\n", name)
+			os.Exit(1)
+		}
+		matches = []types.IndexEntry{entry}
+	} else {
+		matches, err = types.LookupIndex(ev.CacheDir, flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "no store-entry named %s\n", flags.Arg(0))
+			os.Exit(1)
+		}
+	}
+
+	for i, entry := range matches {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(entry.Path)
+		if entry.Source == "" {
+			fmt.Printf("  evaluated from  %s, no origin recorded\n", entry.Root)
+			continue
+		}
+		fmt.Printf("  evaluated from  %s\n", entry.Root)
+		fmt.Printf("  output at       %s\n", entry.Source)
+		attrs := strings.Join(entry.Attrs, ".")
+		if attrs == "" {
+			attrs = "<root>"
+		}
+		fmt.Printf("  attribute       %s\n", attrs)
+		if len(entry.Via) > 0 {
+			fmt.Printf("  dependency of   %s\n", strings.Join(entry.Via, " -> "))
+		}
+	}
+}
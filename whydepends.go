@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/friedelschoen/zon/types"
+	flag "github.com/spf13/pflag"
+)
+
+/* matchPath reports whether `p` is addressed by `query`, which is a store path, store-name, output-name or hash */
+func matchPath(p types.PathExpr, query string) bool {
+	label, hash := pathLabel(p)
+	return p.Name == query || path.Base(p.Name) == query || label == query || (hash != "" && strings.HasPrefix(hash, query))
+}
+
+/* dependencyChain returns the shortest chain of dependencies from a path matching `target` to a path matching `dep` */
+func dependencyChain(roots []types.PathExpr, target string, dep string) []types.PathExpr {
+	var (
+		queue [][]types.PathExpr
+		seen  = make(map[string]bool)
+	)
+	var collect func(p types.PathExpr)
+	collect = func(p types.PathExpr) {
+		if seen[p.Name] {
+			return
+		}
+		seen[p.Name] = true
+		if matchPath(p, target) {
+			queue = append(queue, []types.PathExpr{p})
+		}
+		for _, d := range p.Depends {
+			collect(d)
+		}
+	}
+	for _, root := range roots {
+		collect(root)
+	}
+
+	visited := make(map[string]bool)
+	for len(queue) > 0 {
+		chain := queue[0]
+		queue = queue[1:]
+		last := chain[len(chain)-1]
+		if len(chain) > 1 && matchPath(last, dep) {
+			return chain
+		}
+		if visited[last.Name] {
+			continue
+		}
+		visited[last.Name] = true
+		for _, d := range last.Depends {
+			queue = append(queue, append(chain[:len(chain):len(chain)], d))
+		}
+	}
+	return nil
+}
+
+func whyDependsMain(args []string) {
+	var ev types.Evaluator
+
+	flags := flag.NewFlagSet("why-depends", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: zon why-depends [options] <file.zon> <target> <dependency> [key=value ...]\n")
+		flags.PrintDefaults()
+	}
+	evaluatorFlags(flags, &ev)
+	flags.Parse(args)
+
+	var evalArgs, queries []string
+	for i, arg := range flags.Args() {
+		if i == 0 || strings.Contains(arg, "=") {
+			evalArgs = append(evalArgs, arg)
+		} else {
+			queries = append(queries, arg)
+		}
+	}
+	if len(queries) != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	ev.DryRun = true
+	_, deps, err := evaluate(evalArgs, &ev)
+	if err != nil {
+		exitError(err)
+	}
+
+	chain := dependencyChain(deps, queries[0], queries[1])
+	if chain == nil {
+		fmt.Fprintf(os.Stderr, "%s does not depend on %s\n", queries[0], queries[1])
+		os.Exit(1)
+	}
+	for i, p := range chain {
+		label, hash := pathLabel(p)
+		if hash != "" {
+			label += " (" + hash + ")"
+		}
+		indent := strings.Repeat("    ", max(i-1, 0))
+		if i > 0 {
+			indent += "└── "
+		}
+		fmt.Printf("%s%s  %s\n", indent, label, p.Pos())
+	}
+}